@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/selefra/selefra-provider-sdk/provider/schema"
 	"github.com/selefra/selefra-provider-sdk/storage"
@@ -15,13 +16,15 @@ import (
 	"github.com/selefra/selefra/pkg/grpcClient"
 	"github.com/selefra/selefra/pkg/grpcClient/proto/issue"
 	"github.com/selefra/selefra/pkg/httpClient"
+	"github.com/selefra/selefra/pkg/issuehistory"
 	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/report"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/pkg/utils"
 	"github.com/selefra/selefra/ui"
 	"github.com/spf13/cobra"
-	yaml "gopkg.in/yaml.v3"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -35,7 +38,21 @@ func NewApplyCmd() *cobra.Command {
 		Long:             "Create or update infrastructure",
 		PersistentPreRun: global.DefaultWrappedInit(),
 		RunE:             apply,
+		SilenceUsage:     true,
 	}
+	cmd.PersistentFlags().String("output-format", "", "write the apply result in the given format: json, sarif, junit or csv")
+	cmd.PersistentFlags().String("output-file", "", "the file to write the apply result to, required by --output-format since stdout is used by the progress of apply")
+	cmd.PersistentFlags().String("baseline", "", "only report the issues not found in the baseline, a report file in json format or a run id of the issue history")
+	cmd.PersistentFlags().StringSlice("rule", nil, "only run the rules of the ids or names, prefix with ! to exclude")
+	cmd.PersistentFlags().StringSlice("tag", nil, "only run the rules with the tags, prefix with ! to exclude")
+	cmd.PersistentFlags().StringSlice("severity", nil, "only run the rules of the severities, prefix with ! to exclude")
+	cmd.PersistentFlags().StringSlice("provider", nil, "only run the rules of the providers, prefix with ! to exclude")
+	cmd.PersistentFlags().StringSlice("module", nil, "only run the rules used by the modules and their child modules, prefix with ! to exclude")
+	cmd.PersistentFlags().String("snapshot", "", "run rules on a snapshot instead of syncing providers, a snapshot id or a time which selects the data fetched last before it")
+	cmd.PersistentFlags().Int("parallelism", 4, "the number of rules to run concurrently")
+	cmd.PersistentFlags().Int("provider-parallelism", 4, "the number of provider instances to sync concurrently")
+	cmd.PersistentFlags().Duration("lock-timeout", 0, "fail a provider if the lock of its schema is not acquired in time, such as 10m, 0 waits until the lock is released or its lease expires")
+	cmd.PersistentFlags().String("fail-on", "", "exit with code 2 when any issue at or above the severity is found: informational, low, medium, high, critical")
 
 	cmd.SetHelpFunc(cmd.HelpFunc())
 	return cmd
@@ -45,6 +62,41 @@ func apply(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	_ = login.ShouldLogin()
 
+	outputFormat, _ := cmd.PersistentFlags().GetString("output-format")
+	outputFile, _ := cmd.PersistentFlags().GetString("output-file")
+	if outputFormat == "" && outputFile != "" {
+		outputFormat = report.FormatByPath(outputFile)
+	}
+	if outputFormat != "" {
+		if err := report.CheckFormat(outputFormat); err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		if outputFile == "" {
+			err := errors.New("--output-file is required by --output-format, the output of apply would be mixed with the report on stdout")
+			ui.Errorln(err.Error())
+			return err
+		}
+	}
+	baselineFlag, _ := cmd.PersistentFlags().GetString("baseline")
+	snapshotFlag, _ := cmd.PersistentFlags().GetString("snapshot")
+	parallelism, _ := cmd.PersistentFlags().GetInt("parallelism")
+	providerParallelism, _ := cmd.PersistentFlags().GetInt("provider-parallelism")
+	lockTimeout, _ := cmd.PersistentFlags().GetDuration("lock-timeout")
+	failOn, _ := cmd.PersistentFlags().GetString("fail-on")
+	if failOn != "" {
+		if err := report.CheckSeverity(failOn); err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+	}
+
+	ruleFilter, err := ruleFilterFromFlags(cmd)
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+
 	rootConfig, err := config.GetConfig()
 	if err != nil {
 		ui.Errorln(err.Error())
@@ -57,12 +109,12 @@ func apply(cmd *cobra.Command, args []string) error {
 	_, err = httpClient.TryCreateProject(relvPrjName)
 	if err != nil {
 		ui.Errorln(err.Error())
-		return nil
+		return err
 	}
 	taskRes, err := httpClient.TryCreateTask(relvPrjName)
 	if err != nil {
 		ui.Errorln(err.Error())
-		return nil
+		return err
 	}
 	if taskRes != nil {
 		grpcClient.SetTaskID(taskRes.Data.TaskUUID)
@@ -70,19 +122,26 @@ func apply(cmd *cobra.Command, args []string) error {
 
 	global.SetStage("initializing")
 
-	lockArr, err := provider.Sync(ctx)
-	defer func() {
-		for _, item := range lockArr {
-			err := item.Storage.UnLock(context.Background(), item.SchemaKey, item.Uuid)
-			if err != nil {
-				ui.Errorln(err.Error())
+	// syncErr is returned after rules are applied, some providers may be synced successfully
+	var syncErr error
+	// a snapshot is read as it was fetched, the providers are not synced
+	if snapshotFlag == "" {
+		lockArr, err := provider.Sync(ctx, providerParallelism, lockTimeout)
+		defer func() {
+			for _, item := range lockArr {
+				err := item.Release(context.Background())
+				if err != nil {
+					ui.Errorln(err.Error())
+				}
 			}
+		}()
+		if errors.Is(err, provider.ErrSyncFailed) {
+			syncErr = err
+		} else if err != nil {
+			_ = httpClient.TrySetUpStage(relvPrjName, httpClient.Failed)
+			ui.Errorln(err.Error())
+			return err
 		}
-	}()
-	if err != nil {
-		_ = httpClient.TrySetUpStage(relvPrjName, httpClient.Failed)
-		ui.Errorln(err.Error())
-		return nil
 	}
 
 	var project string
@@ -98,43 +157,98 @@ func apply(cmd *cobra.Command, args []string) error {
 
 	global.SetStage("infrastructure")
 
-	for _, decl := range rootConfig.Selefra.ProviderDecls {
-		prvds := tools.ProvidersByID(rootConfig, decl.Name)
-		for _, prvd := range prvds {
-			schemaKey := config.GetSchemaKey(decl, *prvd)
-			storage, diag := pgstorage.Storage(ctx, pgstorage.WithSearchPath(schemaKey))
-			if diag != nil {
-				err := ui.PrintDiagnostic(diag.GetDiagnosticSlice())
-				if err != nil {
-					return fmt.Errorf("failed to create pgstorage")
-				}
-			}
+	suppressions, expiredSuppressions, err := loadSuppressions(time.Now())
+	if err != nil {
+		ui.Errorln("Load suppressions error:" + err.Error())
+		return err
+	}
+	printExpiredSuppressions(expiredSuppressions)
 
-			modules, err := config.GetModules()
+	var baseline *report.Baseline
+	if baselineFlag != "" {
+		baseline, err = loadBaseline(ctx, baselineFlag)
+		if err != nil {
+			ui.Errorln("Load baseline error:" + err.Error())
+			return err
+		}
+	}
+
+	var applyReport = new(report.Report)
+	// the issues of a snapshot are not the current state, they are not recorded in the issue history
+	var recorder *issuehistory.Recorder
+	if snapshotFlag == "" {
+		recorder = newIssueRecorder(ctx)
+	}
+	if recorder != nil {
+		defer recorder.Close()
+		applyReport.RunId = recorder.RunId()
+	}
+	for _, ps := range tools.ProviderSchemas(rootConfig) {
+		schemaKey := ps.Schema
+		if snapshotFlag != "" {
+			schemaKey, err = snapshotSchema(ctx, schemaKey, snapshotFlag)
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+			ui.Successf("Apply rules on snapshot %s\n", schemaKey)
+		}
+		storage, diag := pgstorage.Storage(ctx, pgstorage.WithSearchPath(schemaKey))
+		if diag != nil {
+			err := ui.PrintDiagnostic(diag.GetDiagnosticSlice())
 			if err != nil {
-				err = httpClient.TrySetUpStage(relvPrjName, httpClient.Failed)
-				ui.Errorln("Client creation error:" + err.Error())
-				return nil
+				return fmt.Errorf("failed to create pgstorage")
 			}
-			ui.Successln(`----------------------------------------------------------------------------------------------
+		}
+
+		ui.Successln(`----------------------------------------------------------------------------------------------
 
 Loading Selefra analysis code ...`)
 
-			var mRules []config.Rule
-			if len(modules) == 0 {
-				mRules = GetAllRules()
-			} else {
-				mRules = GetRules(modules)
+		mRules, err := tools.LoadRules()
+		if err != nil {
+			_ = httpClient.TrySetUpStage(relvPrjName, httpClient.Failed)
+			ui.Errorln("Client creation error:" + err.Error())
+			return err
+		}
+		if !ruleFilter.IsEmpty() {
+			mRules = ruleFilter.Filter(mRules)
+			if len(mRules) == 0 {
+				ui.Warningln("No rule is selected by the rule filters")
 			}
+		}
 
-			ui.Successf("\n---------------------------------- Result for rules  ----------------------------------------\n")
+		ui.Successf("\n---------------------------------- Result for rules  ----------------------------------------\n")
 
-			err = RunRules(ctx, rootConfig, storage, project, mRules, schemaKey)
-			if err != nil {
-				ui.Errorln(err.Error())
-				return nil
-			}
+		err = RunRules(ctx, rootConfig, storage, project, mRules, schemaKey, suppressions, baseline, parallelism, applyReport)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+	}
+
+	if recorder != nil {
+		if err := recorder.Record(ctx, applyReport, time.Now()); err != nil {
+			ui.Errorln("Record issue history error:" + err.Error())
+		}
+	}
 
+	// with a baseline only the new issues are reported, the issue history still records all of them
+	resultReport := applyReport
+	if baseline != nil {
+		resultReport = baseline.Diff(applyReport)
+		printResolved(resultReport.Resolved)
+	}
+
+	printSummary(resultReport, expiredSuppressions, baseline != nil)
+
+	if outputFormat != "" {
+		if err := report.WriteFile(outputFile, outputFormat, resultReport); err != nil {
+			ui.Errorln("Write apply result error:" + err.Error())
+			return err
+		}
+		if outputFile != "" {
+			ui.Successf("\nApply result has been written to %s\n", outputFile)
 		}
 	}
 
@@ -149,8 +263,84 @@ Loading Selefra analysis code ...`)
 		if sErr != nil {
 			ui.Errorln(sErr.Error())
 		}
+		return err
+	}
+
+	return applyResult(resultReport, syncErr, failOn)
+}
+
+// snapshotSchema return the schema of the snapshot of schema selected by target
+func snapshotSchema(ctx context.Context, schema, target string) (string, error) {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return "", errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	return snapshot.Resolve(ctx, sto, schema, target)
+}
+
+// ruleFilterFromFlags build the rule filter from the flags of apply
+func ruleFilterFromFlags(cmd *cobra.Command) (tools.RuleFilter, error) {
+	var filter tools.RuleFilter
+	filter.Rules, _ = cmd.PersistentFlags().GetStringSlice("rule")
+	filter.Tags, _ = cmd.PersistentFlags().GetStringSlice("tag")
+	filter.Severities, _ = cmd.PersistentFlags().GetStringSlice("severity")
+	filter.Providers, _ = cmd.PersistentFlags().GetStringSlice("provider")
+	filter.Modules, _ = cmd.PersistentFlags().GetStringSlice("module")
+	for _, severity := range filter.Severities {
+		if err := report.CheckSeverity(strings.TrimPrefix(severity, "!")); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// newIssueRecorder return the recorder of issue history, apply goes on without history when the storage is not available
+func newIssueRecorder(ctx context.Context) *issuehistory.Recorder {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		ui.Errorln("Record issue history error:" + diag.ToString())
 		return nil
 	}
+	recorder, err := issuehistory.NewRecorder(ctx, sto)
+	if err != nil {
+		sto.Close()
+		ui.Errorln("Record issue history error:" + err.Error())
+		return nil
+	}
+	return recorder
+}
+
+// printSummary print the count of issues, suppressed issues, expired suppressions and failed rules,
+// issues are the new issues and resolved issues are counted too when apply with a baseline
+func printSummary(applyReport *report.Report, expiredSuppressions []config.Suppression, withBaseline bool) {
+	ui.Successf("\n---------------------------------- Summary  ----------------------------------------\n")
+	if applyReport.RunId != "" {
+		ui.Successf("Run id: %s\n", applyReport.RunId)
+	}
+	ui.Successf("Issues: %d        Suppressed: %d        Expired suppressions: %d        Failed rules: %d\n",
+		len(applyReport.Issues), len(applyReport.Suppressed), len(expiredSuppressions), len(applyReport.FailedEvaluations()))
+	if withBaseline {
+		ui.Successf("New issues: %d        Resolved issues: %d\n", len(applyReport.Issues), len(applyReport.Resolved))
+	}
+}
+
+// applyResult decide the result of apply: execution errors take precedence over issues found,
+// issues only fail apply when failOn is set
+func applyResult(applyReport *report.Report, syncErr error, failOn string) error {
+	if syncErr != nil {
+		return syncErr
+	}
+	if failed := applyReport.FailedEvaluations(); len(failed) > 0 {
+		return fmt.Errorf("%d rules failed to run", len(failed))
+	}
+	if failOn != "" {
+		if n := applyReport.CountIssuesAtLeast(failOn); n > 0 {
+			err := fmt.Errorf("%d issues at or above %s severity found", n, failOn)
+			ui.Errorln(err.Error())
+			return global.NewExitError(global.ExitCodeIssuesFound, err)
+		}
+	}
 	return nil
 }
 
@@ -223,7 +413,92 @@ func UploadIssueFunc(ctx context.Context, IssueReq <-chan *issue.Req, ticker *ti
 	}
 }
 
-func RunRules(ctx context.Context, rootConfig *config.RootConfig, storage storage.Storage, project string, rules []config.Rule, schema string) error {
+// toReportRule convert a rule to the rule metadata of report, desc and remediation are the rendered description and remediation
+func toReportRule(rule config.Rule, desc, remediation string) report.Rule {
+	rulePath := rule.Path
+	if rel, err := filepath.Rel(global.WorkSpace(), rulePath); err == nil && !strings.HasPrefix(rel, "..") {
+		rulePath = rel
+	}
+	return report.Rule{
+		Id:          rule.Metadata.Id,
+		Name:        rule.Name,
+		Path:        rulePath,
+		Severity:    rule.Metadata.Severity,
+		Provider:    rule.Metadata.Provider,
+		Title:       rule.Metadata.Title,
+		Description: desc,
+		Author:      rule.Metadata.Author,
+		Remediation: remediation,
+		Tags:        rule.Metadata.Tags,
+		Query:       rule.Query,
+	}
+}
+
+// ruleQueryResult is the result of the query of a rule, err is set when the query template is invalid
+type ruleQueryResult struct {
+	query string
+	rows  *schema.Rows
+	err   error
+	diag  *schema.Diagnostics
+}
+
+// queryRules run the queries of rules by at most parallelism workers, the result of rules[i] is sent to the i-th channel
+func queryRules(ctx context.Context, storage storage.Storage, rules []config.Rule, variablesMap map[string]interface{}, parallelism int) []chan *ruleQueryResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	results := make([]chan *ruleQueryResult, len(rules))
+	for i := range results {
+		results[i] = make(chan *ruleQueryResult, 1)
+	}
+	jobs := make(chan int, len(rules))
+	for i := range rules {
+		jobs <- i
+	}
+	close(jobs)
+	for w := 0; w < parallelism && w < len(rules); w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- queryRule(ctx, storage, rules[i], variablesMap)
+			}
+		}()
+	}
+	return results
+}
+
+// QueryRule render the query of rule with variablesMap and run it on storage, return the rendered query and matched rows
+func QueryRule(ctx context.Context, storage storage.Storage, rule config.Rule, variablesMap map[string]interface{}) (string, *schema.Rows, error) {
+	result := queryRule(ctx, storage, rule, variablesMap)
+	if result.err != nil {
+		return result.query, nil, result.err
+	}
+	if result.diag != nil {
+		return result.query, nil, errors.New(result.diag.ToString())
+	}
+	return result.query, result.rows, nil
+}
+
+func queryRule(ctx context.Context, storage storage.Storage, rule config.Rule, variablesMap map[string]interface{}) *ruleQueryResult {
+	queryStr, err := fmtTemplate(rule.Query, variablesMap)
+	if err != nil {
+		return &ruleQueryResult{err: err}
+	}
+	res, diag := storage.Query(ctx, queryStr)
+	if diag != nil {
+		return &ruleQueryResult{query: queryStr, diag: diag}
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil {
+		return &ruleQueryResult{query: queryStr, diag: diag}
+	}
+	return &ruleQueryResult{query: queryStr, rows: rows}
+}
+
+// RunRules run rules on the schema, print the issues, send them to selefra cloud and collect them into applyReport
+// rows matched by a rule but silenced by suppressions are only collected as suppressed issues,
+// issues in baseline are collected and sent but not printed.
+// the queries are run by parallelism workers, the results are handled in the order of rules
+func RunRules(ctx context.Context, rootConfig *config.RootConfig, storage storage.Storage, project string, rules []config.Rule, schema string, suppressions []config.Suppression, baseline *report.Baseline, parallelism int, applyReport *report.Report) error {
 	issueCtx, issueCancel := context.WithCancel(context.Background())
 	defer issueCancel()
 	issueChan := make(chan *issue.Req, 100)
@@ -242,70 +517,106 @@ func RunRules(ctx context.Context, rootConfig *config.RootConfig, storage storag
 		}
 	}()
 
-	go UploadIssueFunc(issueCtx, issueChan, ticker)
+	// the issues are not sent once the upload ended, e.g. on a send error, so a full issueChan never blocks the rules
+	go func() {
+		UploadIssueFunc(issueCtx, issueChan, ticker)
+		issueCancel()
+	}()
 
-	for _, rule := range rules {
-		var variablesMap = make(map[string]interface{})
-		for i := range rootConfig.Variables {
-			variablesMap[rootConfig.Variables[i].Key] = rootConfig.Variables[i].Default
-		}
-		queryStr, err := fmtTemplate(rule.Query, variablesMap)
-		res, diag := storage.Query(ctx, queryStr)
-		if diag != nil {
-			ui.PrintDiagnostic(diag.GetDiagnosticSlice())
+	variablesMap := tools.VariablesMap(rootConfig)
+	queryCtx, queryCancel := context.WithCancel(ctx)
+	defer queryCancel()
+	results := queryRules(queryCtx, storage, rules, variablesMap, parallelism)
+
+	// tableMap is the tables of schema, it is listed once when the first issue is found
+	var tableMap map[string]bool
+
+	for ruleIndex, rule := range rules {
+		result := <-results[ruleIndex]
+		evaluation := applyReport.AddEvaluation(toReportRule(rule, rule.Metadata.Description, rule.Metadata.Remediation), schema)
+		if result.err != nil {
+			evaluation.Error = result.err.Error()
 			continue
 		}
-		table, diag := res.ReadRows(-1)
-		if diag != nil {
-			ui.PrintDiagnostic(diag.GetDiagnosticSlice())
+		if result.diag != nil {
+			evaluation.Error = result.diag.ToString()
+			ui.PrintDiagnostic(result.diag.GetDiagnosticSlice())
 			continue
 		}
+		queryStr := result.query
+		table := result.rows
 		column := table.GetColumnNames()
-		rows := table.GetMatrix()
+		var rows []map[string]interface{}
+		var newRows int
+		for _, row := range table.GetMatrix() {
+			var rowMap = make(map[string]interface{})
+			for index, value := range row {
+				rowMap[column[index]] = value
+			}
+			labels := fmtLabels(rule.Labels, rowMap)
+			if suppression := matchSuppression(suppressions, rule, labels, rowMap); suppression != nil {
+				out, _ := fmtTemplate(rule.Output, rowMap)
+				applyReport.AddSuppressed(evaluation, &report.Issue{
+					Fingerprint: fingerprint(rule, schema, rowMap),
+					Rule:        evaluation.Rule,
+					Schema:      schema,
+					Output:      out,
+					Labels:      labels,
+					Row:         rowMap,
+					Suppression: suppression.Reason,
+				})
+				continue
+			}
+			rows = append(rows, rowMap)
+			if !baseline.Has(fingerprint(rule, schema, rowMap)) {
+				newRows++
+			}
+		}
 		if len(rows) == 0 {
 			continue
 		}
-		ui.Successf("%rootConfig - Rule \"%rootConfig\"\n", rule.Path, rule.Name)
-		ui.Successln("Schema:")
-		ui.Successln(schema + "\n")
-		ui.Successln("Description:")
+		// the rule is only printed when it found issues not in baseline
+		printRule := newRows > 0
+		if printRule {
+			ui.Successf("%s - Rule \"%s\"\n", rule.Path, rule.Name)
+			ui.Successln("Schema:")
+			ui.Successln(schema + "\n")
+			ui.Successln("Description:")
+		}
 
 		desc, err := fmtTemplate(rule.Metadata.Description, variablesMap)
 		if err != nil {
 			ui.Errorln(err.Error())
 			return err
 		}
-		ui.Successln("	" + desc)
+		if printRule {
+			ui.Successln("	" + desc)
+			ui.Successln("Policy:")
+		}
+		evaluation.Rule.Description = desc
 
-		ui.Successln("Policy:")
-		schemaTables, schemaDiag := storage.TableList(ctx, schema)
-		if schemaDiag != nil {
-			err := ui.PrintDiagnostic(schemaDiag.GetDiagnosticSlice())
-			if err != nil {
-				return err
+		if tableMap == nil {
+			schemaTables, schemaDiag := storage.TableList(ctx, schema)
+			if schemaDiag != nil {
+				err := ui.PrintDiagnostic(schemaDiag.GetDiagnosticSlice())
+				if err != nil {
+					return err
+				}
 			}
+			tableMap = make(map[string]bool)
+			getTableMap(tableMap, schemaTables)
 		}
-		var tableMap = make(map[string]bool)
-		getTableMap(tableMap, schemaTables)
 
 		uploadTables := getSqlTables(queryStr, tableMap)
-		if err != nil {
-			ui.Errorln(err.Error())
-			return err
+		if printRule {
+			ui.Successln("	" + queryStr)
+			ui.Successln("Output")
 		}
-		ui.Successln("	" + queryStr)
-
-		ui.Successln("Output")
 		for _, row := range rows {
 			var outMetaData issue.Metadata
-			var baseRow = make(map[string]interface{})
 			var outPut = rule.Output
-			var outMap = make(map[string]interface{})
-			for index, value := range row {
-				key := column[index]
-				outMap[key] = value
-			}
-			baseRow = outMap
+			var outMap = row
+			var baseRow = row
 			baseRowStr, err := json.Marshal(baseRow)
 			if err != nil {
 				ui.Errorln(err.Error())
@@ -343,24 +654,25 @@ func RunRules(ctx context.Context, rootConfig *config.RootConfig, storage storag
 				Output:       outByte.String(),
 			}
 
-			ui.Successln("	" + out)
-
-			var outLabel = make(map[string]string)
-			for key := range rule.Labels {
-				switch rule.Labels[key].(type) {
-				case string:
-					outStr, _ := fmtTemplate(rule.Labels[key].(string), baseRow)
-					outLabel[key] = outStr
-				case []string:
-					var out []string
-					for _, v := range rule.Labels[key].([]string) {
-						s, _ := fmtTemplate(v, baseRow)
-						out = append(out, s)
-					}
-					outLabel[key] = strings.Join(out, ",")
-				}
+			issueFingerprint := fingerprint(rule, schema, baseRow)
+			if !baseline.Has(issueFingerprint) {
+				ui.Successln("	" + out)
 			}
 
+			outLabel := fmtLabels(rule.Labels, baseRow)
+
+			reportRule := evaluation.Rule
+			reportRule.Remediation = remediation
+			applyReport.AddIssue(evaluation, &report.Issue{
+				Fingerprint: issueFingerprint,
+				Rule:        reportRule,
+				Schema:      schema,
+				Output:      out,
+				Labels:      outLabel,
+				SrcTables:   uploadTables,
+				Row:         baseRow,
+			})
+
 			reqs := issue.Req{
 				Name:        rule.Name,
 				Query:       rule.Query,
@@ -371,113 +683,48 @@ func RunRules(ctx context.Context, rootConfig *config.RootConfig, storage storag
 				Token:       grpcClient.Token(),
 				Schema:      schema,
 			}
+			// the report, the issue history and the exit code do not depend on the upload to selefra cloud,
+			// the issue is collected above even if it is not sent
 			select {
+			case issueChan <- &reqs:
 			case <-issueCtx.Done():
-				return nil
-			default:
-				issueChan <- &reqs
 			}
 		}
 	}
 	return nil
 }
 
-// GetAllRules get all rules from workspace
-func GetAllRules() []config.Rule {
-	rules, _ := config.GetRules()
-	for i := range rules.Rules {
-		if strings.HasPrefix(rules.Rules[i].Query, ".") {
-			sqlByte, err := os.ReadFile(filepath.Join(".", rules.Rules[i].Query))
-			if err != nil {
-				ui.Errorf("sql open error:%s", err.Error())
-				return nil
-			}
-			rules.Rules[i].Query = string(sqlByte)
-		}
-	}
-	return rules.Rules
+// fingerprint return the identity of the issue found by rule on the row
+func fingerprint(rule config.Rule, schema string, row map[string]interface{}) string {
+	ruleKey := report.Rule{Id: rule.Metadata.Id, Name: rule.Name}.Key()
+	return issuehistory.Fingerprint(ruleKey, schema, issuehistory.KeyColumns(rule.Metadata.KeyColumns, row), row)
 }
 
-// GetRules find all rules in modules
-func GetRules(modules []config.Module) []config.Rule {
-	var rules []config.Rule
-	for _, module := range modules {
-		if rule := GetModuleRules(module); rule != nil {
-			rules = append(rules, rule...)
-		}
-	}
-	return rules
-}
-
-// GetModuleRules find all rules according to given module
-func GetModuleRules(module config.Module) []config.Rule {
-	var resRule config.RuleSet
-	var b []byte
-	var err error
-
-	for _, use := range module.Uses {
-		var usePath string
-		if path.IsAbs(use) || strings.Index(use, "://") > -1 {
-			usePath = use
-		} else {
-			usePath = filepath.Join(global.WorkSpace(), use)
-		}
-		if strings.Index(usePath, "://") > -1 {
-			d := config.Downloader{Url: usePath}
-			b, err = d.Get()
-			if err != nil {
-				ui.Errorln(err.Error())
-				return nil
+// fmtLabels render the labels of a rule with the row matched by the rule
+func fmtLabels(labels map[string]interface{}, row map[string]interface{}) map[string]string {
+	var outLabel = make(map[string]string)
+	for key := range labels {
+		switch labels[key].(type) {
+		case string:
+			outStr, _ := fmtTemplate(labels[key].(string), row)
+			outLabel[key] = outStr
+		case []string:
+			var out []string
+			for _, v := range labels[key].([]string) {
+				s, _ := fmtTemplate(v, row)
+				out = append(out, s)
 			}
-		} else {
-			b, err = os.ReadFile(usePath)
-			if err != nil {
-				ui.Errorln(err.Error())
-				return nil
-			}
-		}
-
-		var baseRule config.RuleSet
-		err = yaml.Unmarshal(b, &baseRule)
-		if err != nil {
-			ui.Errorln(err.Error())
-			return nil
-		}
-
-		if err != nil {
-			ui.Errorln(err.Error())
-			return nil
-		}
-		var ruleConfig config.RuleSet
-		err = yaml.Unmarshal([]byte(string(b)), &ruleConfig)
-		if err != nil {
-			ui.Errorln(err.Error())
-			return nil
-		}
-		for i := range ruleConfig.Rules {
-			ruleConfig.Rules[i].Output = baseRule.Rules[i].Output
-			ruleConfig.Rules[i].Query = baseRule.Rules[i].Query
-			ruleConfig.Rules[i].Path = use
-			_, err := os.Stat(filepath.Join(global.WorkSpace(), ruleConfig.Rules[i].Query))
-			if err == nil {
-				var sqlPath string
-				if filepath.IsAbs(ruleConfig.Rules[i].Query) {
-					sqlPath = ruleConfig.Rules[i].Query
-				} else {
-					sqlPath = filepath.Join(global.WorkSpace(), ruleConfig.Rules[i].Query)
-				}
-				sqlByte, err := os.ReadFile(sqlPath)
-				if err != nil {
-					ui.Errorf("sql open error:%s", err.Error())
-					return nil
-				}
-				ruleConfig.Rules[i].Query = string(sqlByte)
+			outLabel[key] = strings.Join(out, ",")
+		case []interface{}:
+			var out []string
+			for _, v := range labels[key].([]interface{}) {
+				s, _ := fmtTemplate(utils.Strava(v), row)
+				out = append(out, s)
 			}
-			ui.Successf("	%s - Rule %s: loading ... \n", use, baseRule.Rules[i].Name)
+			outLabel[key] = strings.Join(out, ",")
 		}
-		resRule.Rules = append(resRule.Rules, ruleConfig.Rules...)
 	}
-	return resRule.Rules
+	return outLabel
 }
 
 func fmtTemplate(temp string, params map[string]interface{}) (string, error) {
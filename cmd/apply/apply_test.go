@@ -1,10 +1,17 @@
 package apply
 
 import (
+	"context"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-provider-sdk/storage"
+	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
 	"github.com/stretchr/testify/require"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 //func TestApply(t *testing.T) {
@@ -22,17 +29,80 @@ func Test_RunPathModule(t *testing.T) {
 
 	require.Equal(t, "Misconfigure-S3", modules[0].Name)
 
-	rules := GetModuleRules(modules[0])
+	rules := tools.GetModuleRules(modules[0])
 
 	require.Equal(t, 1, len(rules), "rules length error")
 	require.Equal(t, "ebs_volume_are_unencrypted", rules[0].Name, "rules name error")
+	require.Equal(t, "Misconfigure-S3", rules[0].Module, "rules module error")
 }
 
 func Test_RunRulesWithoutModule(t *testing.T) {
 	global.Init("", global.WithWorkspace("../../tests/workspace/offline"))
 
-	rules := GetAllRules()
+	rules := tools.GetAllRules()
 
 	require.Equal(t, 1, len(rules), "rules length error")
 	require.Equal(t, "ebs_volume_are_unencrypted", rules[0].Name, "rules name error")
 }
+
+// fakeStorage answer every query with a row of the query itself, only Query is implemented
+type fakeStorage struct {
+	storage.Storage
+	running int32
+	max     int32
+}
+
+type fakeQueryResult struct {
+	storage.QueryResult
+	query string
+}
+
+func (f *fakeQueryResult) ReadRows(rowLimit int) (*schema.Rows, *schema.Diagnostics) {
+	rows := schema.NewRows("query")
+	_ = rows.AppendRowValues([]any{f.query})
+	return rows, nil
+}
+
+func (f *fakeStorage) Query(ctx context.Context, query string, args ...any) (storage.QueryResult, *schema.Diagnostics) {
+	running := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		max := atomic.LoadInt32(&f.max)
+		if running <= max || atomic.CompareAndSwapInt32(&f.max, max, running) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(len(query)) * time.Millisecond)
+	return &fakeQueryResult{query: query}, nil
+}
+
+func Test_queryRules(t *testing.T) {
+	var rules []config.Rule
+	for i := 0; i < 8; i++ {
+		rules = append(rules, config.Rule{Query: "SELECT " + strings.Repeat("x", 8-i) + " FROM {{.table}}"})
+	}
+	sto := &fakeStorage{}
+	results := queryRules(context.Background(), sto, rules, map[string]interface{}{"table": "t"}, 3)
+	for i, rule := range rules {
+		result := <-results[i]
+		require.NoError(t, result.err)
+		require.Nil(t, result.diag)
+		require.Equal(t, strings.Replace(rule.Query, "{{.table}}", "t", 1), result.rows.GetMatrix()[0][0])
+	}
+	require.LessOrEqual(t, sto.max, int32(3))
+}
+
+func Test_ruleFilterFromFlags(t *testing.T) {
+	cmd := NewApplyCmd()
+	require.NoError(t, cmd.PersistentFlags().Parse([]string{"--rule", "SF010302,!SF010303", "--severity", "high", "--severity", "!low", "--module", "Misconfigure-S3"}))
+	filter, err := ruleFilterFromFlags(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []string{"SF010302", "!SF010303"}, filter.Rules)
+	require.Equal(t, []string{"high", "!low"}, filter.Severities)
+	require.Equal(t, []string{"Misconfigure-S3"}, filter.Modules)
+
+	cmd = NewApplyCmd()
+	require.NoError(t, cmd.PersistentFlags().Parse([]string{"--severity", "!urgent"}))
+	_, err = ruleFilterFromFlags(cmd)
+	require.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"github.com/selefra/selefra/pkg/issuehistory"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/report"
+	"github.com/selefra/selefra/ui"
+	"os"
+)
+
+// loadBaseline load the baseline from a report file written in json format, baseline is treated as a run id
+// of the issue history when no file exists at it
+func loadBaseline(ctx context.Context, baseline string) (*report.Baseline, error) {
+	if _, err := os.Stat(baseline); err == nil {
+		return report.ReadBaseline(baseline)
+	}
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	issues, err := issuehistory.RunIssues(ctx, sto, baseline)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewBaseline(issues), nil
+}
+
+// printResolved print the issues of the baseline which are resolved
+func printResolved(resolved []*report.Issue) {
+	if len(resolved) == 0 {
+		return
+	}
+	ui.Successf("\n---------------------------------- Resolved issues  ----------------------------------------\n")
+	for _, issue := range resolved {
+		ui.Successf("%s - Rule \"%s\" - Schema %s\n", issue.Rule.Key(), issue.Rule.Name, issue.Schema)
+		if issue.Output != "" {
+			ui.Successln("	" + issue.Output)
+		}
+	}
+}
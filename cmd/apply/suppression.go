@@ -0,0 +1,60 @@
+package apply
+
+import (
+	"time"
+
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+)
+
+// loadSuppressions load the suppressions of workspace and split them into active and expired ones
+func loadSuppressions(now time.Time) (active []config.Suppression, expired []config.Suppression, err error) {
+	suppressions, err := config.GetSuppressions()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, suppression := range suppressions {
+		if suppression.Expired(now) {
+			expired = append(expired, suppression)
+			continue
+		}
+		active = append(active, suppression)
+	}
+	return active, expired, nil
+}
+
+// printExpiredSuppressions warn the user that the expired suppressions no longer silence issues
+func printExpiredSuppressions(expired []config.Suppression) {
+	for _, suppression := range expired {
+		ui.Warningf("%s - Suppression for rule %s expired at %s, reason: %s\n", suppression.Path, suppression.RuleId, suppression.Expires, suppression.Reason)
+	}
+}
+
+// matchSuppression return the first suppression which silences the row matched by rule, nil if no suppression matches
+func matchSuppression(suppressions []config.Suppression, rule config.Rule, labels map[string]string, row map[string]interface{}) *config.Suppression {
+	for i := range suppressions {
+		suppression := &suppressions[i]
+		if suppression.RuleId != rule.Metadata.Id && suppression.RuleId != rule.Name {
+			continue
+		}
+		if suppressionMatchRow(suppression, labels, row) {
+			return suppression
+		}
+	}
+	return nil
+}
+
+// suppressionMatchRow check that every value in suppression.Match equals the label or the column of the row with the same key
+func suppressionMatchRow(suppression *config.Suppression, labels map[string]string, row map[string]interface{}) bool {
+	for key, value := range suppression.Match {
+		if label, ok := labels[key]; ok && label == value {
+			continue
+		}
+		if column, ok := row[key]; ok && utils.Strava(column) == value {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package apply
+
+import (
+	"github.com/selefra/selefra/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_matchSuppression(t *testing.T) {
+	var rule config.Rule
+	rule.Name = "bucket_publicly_readable"
+	rule.Metadata.Id = "SF010101"
+
+	suppressions := []config.Suppression{
+		{
+			RuleId: "SF010101",
+			Match:  map[string]string{"arn": "arn:aws:s3:::public-assets"},
+			Reason: "public website bucket",
+		},
+		{
+			RuleId: "other_rule",
+			Reason: "not used",
+		},
+	}
+
+	row := map[string]interface{}{"arn": "arn:aws:s3:::public-assets"}
+	suppression := matchSuppression(suppressions, rule, map[string]string{}, row)
+	require.NotNil(t, suppression)
+	require.Equal(t, "public website bucket", suppression.Reason)
+
+	row = map[string]interface{}{"arn": "arn:aws:s3:::private"}
+	require.Nil(t, matchSuppression(suppressions, rule, map[string]string{}, row))
+
+	labels := map[string]string{"arn": "arn:aws:s3:::public-assets"}
+	require.NotNil(t, matchSuppression(suppressions, rule, labels, row))
+
+	suppressions = append(suppressions, config.Suppression{RuleId: "bucket_publicly_readable", Reason: "accepted risk"})
+	suppression = matchSuppression(suppressions, rule, map[string]string{}, row)
+	require.NotNil(t, suppression)
+	require.Equal(t, "accepted risk", suppression.Reason)
+}
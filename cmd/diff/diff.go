@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func NewDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "diff",
+		Short:            "Show the resources added, removed and modified between two fetches",
+		Long:             "Show the resources added, removed and modified between two fetches of every provider, --from and --to are current, a snapshot id or a time which selects the data fetched last before it",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			tables, _ := cmd.Flags().GetStringSlice("table")
+			output, _ := cmd.Flags().GetString("output")
+			if output != outputTable && output != outputJSON {
+				err := fmt.Errorf("unsupported output %s, must be table or json", output)
+				ui.Errorln(err.Error())
+				return err
+			}
+			return Diff(cmd.Context(), from, to, tables, output)
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().String("from", "", "the snapshot to compare from")
+	cmd.Flags().String("to", snapshot.Current, "the snapshot to compare to")
+	cmd.Flags().StringSlice("table", nil, "only compare the tables, all tables are compared by default")
+	cmd.Flags().String("output", outputTable, "the output format: table or json")
+	_ = cmd.MarkFlagRequired("from")
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+// providerDiff is the resource changes of a provider between two fetches
+type providerDiff struct {
+	Provider string                `json:"provider"`
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Tables   []*snapshot.TableDiff `json:"tables"`
+}
+
+// Diff compare the data of every provider fetched at from and to, only tables are compared if it is not empty
+func Diff(ctx context.Context, from, to string, tables []string, output string) error {
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		ui.Errorln(diag.ToString())
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	var diffs []*providerDiff
+	var found = make(map[string]bool)
+	for _, ps := range tools.ProviderSchemas(rootConfig) {
+		fromSchema, err := snapshot.Resolve(ctx, sto, ps.Schema, from)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		toSchema, err := snapshot.Resolve(ctx, sto, ps.Schema, to)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		tableDiffs, err := snapshot.Diff(ctx, sto, fromSchema, toSchema, tables)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		for _, d := range tableDiffs {
+			found[d.Table] = true
+		}
+		diffs = append(diffs, &providerDiff{
+			Provider: ps.Provider.Name,
+			From:     fromSchema,
+			To:       toSchema,
+			Tables:   tableDiffs,
+		})
+	}
+	for _, t := range tables {
+		if !found[t] {
+			err := fmt.Errorf("table %s is not found in any provider", t)
+			ui.Errorln(err.Error())
+			return err
+		}
+	}
+
+	if output == outputJSON {
+		if diffs == nil {
+			diffs = []*providerDiff{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(diffs)
+	}
+	printDiffs(diffs)
+	return nil
+}
+
+func printDiffs(diffs []*providerDiff) {
+	var changed bool
+	for _, pd := range diffs {
+		for _, td := range pd.Tables {
+			if td.Empty() {
+				continue
+			}
+			changed = true
+			ui.Successf("\n%s %s (%s -> %s): %d added, %d removed, %d modified\n", pd.Provider, td.Table, pd.From, pd.To, td.Added, td.Removed, td.Modified)
+			if td.Duplicates > 0 {
+				ui.Warningf("%d rows of %s have the same key as another row, only one of them is compared\n", td.Duplicates, td.Table)
+			}
+			var body [][]string
+			for _, c := range td.Changes {
+				body = append(body, []string{c.Change, fmtKey(c.Key), fmtColumns(c.Columns)})
+			}
+			table.ShowTable([]string{"Change", "Resource", "Changed Columns"}, body, []string{}, true)
+		}
+	}
+	if !changed {
+		ui.Successln("No resource is changed")
+	}
+}
+
+func fmtKey(key map[string]interface{}) string {
+	var columns []string
+	for column := range key {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	var parts []string
+	for _, column := range columns {
+		parts = append(parts, column+"="+utils.Strava(key[column]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func fmtColumns(columns []snapshot.ColumnChange) string {
+	var lines []string
+	for _, c := range columns {
+		lines = append(lines, fmt.Sprintf("%s: %s -> %s", c.Column, utils.Strava(c.From), utils.Strava(c.To)))
+	}
+	return strings.Join(lines, "\n")
+}
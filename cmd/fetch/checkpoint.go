@@ -0,0 +1,149 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/utils"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// checkpointKeyPrefix prefix the keys in selefra_meta_kv of the staging schema which record the finished root tables
+	checkpointKeyPrefix = "checkpoint:"
+	// checkpointStartedKey is the key in selefra_meta_kv of the staging schema which record when the pull started
+	checkpointStartedKey = "checkpoint_started"
+)
+
+func checkpointKey(table string) string {
+	return checkpointKeyPrefix + table
+}
+
+// checkpoint is the progress of an interrupted pull into a staging schema
+type checkpoint struct {
+	started  time.Time
+	finished map[string]bool
+}
+
+// readCheckpoint return the checkpoint of the pull into the staging schema of schema, nil if no pull was interrupted
+func readCheckpoint(ctx context.Context, schema string) (*checkpoint, error) {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	res, diag := sto.Query(ctx, fmt.Sprintf(`SELECT "key", value FROM %s.selefra_meta_kv`, stagingSchema(schema)))
+	if diag != nil && diag.HasError() {
+		// the staging schema or its key value table does not exist
+		return nil, nil
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	cp := &checkpoint{finished: make(map[string]bool)}
+	for _, row := range rows.GetMatrix() {
+		key, value := utils.Strava(row[0]), utils.Strava(row[1])
+		switch {
+		case key == checkpointStartedKey:
+			cp.started, _ = time.Parse(time.RFC3339, value)
+		case strings.HasPrefix(key, checkpointKeyPrefix):
+			cp.finished[strings.TrimPrefix(key, checkpointKeyPrefix)] = true
+		}
+	}
+	if cp.started.IsZero() {
+		return nil, nil
+	}
+	return cp, nil
+}
+
+// flatTable return the name of table and all its sub tables
+func flatTable(table *schema.Table) []string {
+	if table == nil {
+		return nil
+	}
+	names := []string{table.TableName}
+	for _, sub := range table.SubTables {
+		names = append(names, flatTable(sub)...)
+	}
+	return names
+}
+
+// resumePlan return the root tables to pull for the requested tables and the tables to drop before pulling,
+// the root tables finished by the interrupted pull are skipped, the other ones may be pulled partially
+// so they and their sub tables are dropped to be created again
+func resumePlan(roots map[string]*schema.Table, requested []string, finished map[string]bool) (pull []string, drop []string) {
+	for root := range requestedRoots(roots, requested) {
+		if finished[root] {
+			continue
+		}
+		pull = append(pull, root)
+		drop = append(drop, flatTable(roots[root])...)
+	}
+	sort.Strings(pull)
+	sort.Strings(drop)
+	return pull, drop
+}
+
+// keptTables return the tables which are not pulled for the requested tables, they are the root tables
+// not requested and their sub tables
+func keptTables(roots map[string]*schema.Table, requested []string) []string {
+	pulled := requestedRoots(roots, requested)
+	var kept []string
+	for name, root := range roots {
+		if !pulled[name] {
+			kept = append(kept, flatTable(root)...)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
+
+// requestedRoots return the root tables of the requested tables, a requested table may be a pattern such as aws_s3_*
+// which requests the roots of the tables it matches, * requests all of them
+func requestedRoots(roots map[string]*schema.Table, requested []string) map[string]bool {
+	var rootOf = make(map[string]string)
+	for name, root := range roots {
+		for _, table := range flatTable(root) {
+			rootOf[table] = name
+		}
+	}
+
+	var requestedRoots = make(map[string]bool)
+	for _, name := range requested {
+		if strings.ContainsAny(name, "*?[") {
+			for table, root := range rootOf {
+				if ok, _ := path.Match(name, table); ok {
+					requestedRoots[root] = true
+				}
+			}
+			continue
+		}
+		if root, ok := rootOf[name]; ok {
+			requestedRoots[root] = true
+		} else {
+			// an unknown table is left for the provider to report
+			requestedRoots[name] = true
+		}
+	}
+	return requestedRoots
+}
+
+// unfinishedRoots return the requested root tables which are not finished, the tables unknown to the provider are
+// reported by the provider rather than here
+func unfinishedRoots(roots map[string]*schema.Table, requested []string, finished map[string]bool) []string {
+	var unfinished []string
+	for root := range requestedRoots(roots, requested) {
+		if _, ok := roots[root]; ok && !finished[root] {
+			unfinished = append(unfinished, root)
+		}
+	}
+	sort.Strings(unfinished)
+	return unfinished
+}
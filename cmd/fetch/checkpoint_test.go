@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResumePlan(t *testing.T) {
+	roots := map[string]*schema.Table{
+		"aws_s3_buckets": {
+			TableName: "aws_s3_buckets",
+			SubTables: []*schema.Table{
+				{TableName: "aws_s3_bucket_grants"},
+			},
+		},
+		"aws_ec2_instances": {TableName: "aws_ec2_instances"},
+		"aws_iam_users":     {TableName: "aws_iam_users"},
+	}
+	finished := map[string]bool{"aws_iam_users": true}
+
+	pull, drop := resumePlan(roots, []string{"*"}, finished)
+	require.Equal(t, []string{"aws_ec2_instances", "aws_s3_buckets"}, pull)
+	require.Equal(t, []string{"aws_ec2_instances", "aws_s3_bucket_grants", "aws_s3_buckets"}, drop)
+
+	pull, drop = resumePlan(roots, []string{"aws_s3_bucket_grants", "aws_iam_users"}, finished)
+	require.Equal(t, []string{"aws_s3_buckets"}, pull)
+	require.Equal(t, []string{"aws_s3_bucket_grants", "aws_s3_buckets"}, drop)
+
+	pull, drop = resumePlan(roots, []string{"aws_iam_users"}, finished)
+	require.Empty(t, pull)
+	require.Empty(t, drop)
+
+	// patterns are expanded, the finished roots they match are not pulled again
+	pull, drop = resumePlan(roots, []string{"aws_s3_bucket_*", "aws_i*"}, finished)
+	require.Equal(t, []string{"aws_s3_buckets"}, pull)
+	require.Equal(t, []string{"aws_s3_bucket_grants", "aws_s3_buckets"}, drop)
+
+	pull, drop = resumePlan(roots, []string{"aws_ec2_*", "aws_iam_*", "gcp_*"}, finished)
+	require.Equal(t, []string{"aws_ec2_instances"}, pull)
+	require.Equal(t, []string{"aws_ec2_instances"}, drop)
+}
+
+func TestKeptTables(t *testing.T) {
+	roots := map[string]*schema.Table{
+		"aws_s3_buckets": {
+			TableName: "aws_s3_buckets",
+			SubTables: []*schema.Table{
+				{TableName: "aws_s3_bucket_grants"},
+			},
+		},
+		"aws_ec2_instances": {TableName: "aws_ec2_instances"},
+	}
+
+	require.Equal(t, []string{"aws_s3_bucket_grants", "aws_s3_buckets"}, keptTables(roots, []string{"aws_ec2_instances"}))
+	require.Equal(t, []string{"aws_ec2_instances"}, keptTables(roots, []string{"aws_s3_bucket_grants"}))
+	require.Empty(t, keptTables(roots, []string{"*"}))
+	require.Equal(t, []string{"aws_ec2_instances"}, keptTables(roots, []string{"aws_s3_*"}))
+	require.Equal(t, []string{"aws_s3_bucket_grants", "aws_s3_buckets"}, keptTables(roots, []string{"aws_ec2_?nstances"}))
+	require.Equal(t, []string{"aws_ec2_instances"}, keptTables(roots, []string{"*_grants"}))
+	require.Equal(t, []string{"aws_ec2_instances", "aws_s3_bucket_grants", "aws_s3_buckets"}, keptTables(roots, []string{"gcp_*"}))
+}
+
+func TestUnfinishedRoots(t *testing.T) {
+	roots := map[string]*schema.Table{
+		"aws_s3_buckets": {
+			TableName: "aws_s3_buckets",
+			SubTables: []*schema.Table{
+				{TableName: "aws_s3_bucket_grants"},
+			},
+		},
+		"aws_ec2_instances": {TableName: "aws_ec2_instances"},
+	}
+
+	require.Equal(t, []string{"aws_ec2_instances"}, unfinishedRoots(roots, []string{"*"}, map[string]bool{"aws_s3_buckets": true}))
+	require.Equal(t, []string{"aws_s3_buckets"}, unfinishedRoots(roots, []string{"aws_s3_bucket_grants", "aws_unknown"}, nil))
+	require.Empty(t, unfinishedRoots(roots, []string{"aws_ec2_instances"}, map[string]bool{"aws_ec2_instances": true}))
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/selefra/selefra-provider-sdk/grpc/shard"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-utils/pkg/id_util"
 	"github.com/selefra/selefra-utils/pkg/pointer"
 	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
@@ -19,68 +21,257 @@ import (
 	"gopkg.in/yaml.v3"
 	"io"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 func NewFetchCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:              "fetch",
 		Short:            "Fetch resources from configured providers",
-		Long:             "Fetch resources from configured providers",
+		Long:             "Fetch resources from configured providers, the resources within their cache time are skipped unless --force is given",
 		PersistentPreRun: global.DefaultWrappedInit(),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			errFlag := false
 			ctx := cmd.Context()
+			parallelism, _ := cmd.PersistentFlags().GetInt("provider-parallelism")
+			resume, _ := cmd.PersistentFlags().GetBool("resume")
+			output, _ := cmd.PersistentFlags().GetString("output")
+			outputFile, _ := cmd.PersistentFlags().GetString("output-file")
+			providers, _ := cmd.PersistentFlags().GetStringSlice("provider")
+			resources, _ := cmd.PersistentFlags().GetStringSlice("resources")
+			force, _ := cmd.PersistentFlags().GetBool("force")
+			allowPartial, _ := cmd.PersistentFlags().GetBool("allow-partial")
+			lockTimeout, _ := cmd.PersistentFlags().GetDuration("lock-timeout")
+			if output != outputTable && output != outputJSON {
+				err := fmt.Errorf("unsupported output %s, must be table or json", output)
+				ui.Errorln(err.Error())
+				return err
+			}
+			if output == outputJSON && outputFile == "" {
+				err := errors.New("--output-file is required by --output json, the progress of fetch would be mixed with the report on stdout")
+				ui.Errorln(err.Error())
+				return err
+			}
 			rootConfig, err := config.GetConfig()
 			if err != nil {
 				return err
 			}
 			ui.Successf("Selefra start fetch")
-			for _, p := range rootConfig.Selefra.ProviderDecls {
-				prvds := tools.ProvidersByID(rootConfig, p.Name)
-				for _, prvd := range prvds {
-					err = Fetch(ctx, p, prvd)
-					if err != nil {
-						ui.Errorln(err.Error())
-						errFlag = true
-						return err
-					}
+			instances, err := selectInstances(tools.ProviderSchemas(rootConfig), providers)
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+			progbar := progress.CreateProgress()
+			var reports *Reports
+			if output == outputJSON {
+				reports = &Reports{}
+			}
+			var failed int32
+			utils.Parallel(len(instances), parallelism, func(i int) {
+				opts := FetchOptions{Progress: progbar, Resume: resume, Reports: reports, AllowPartial: allowPartial}
+				if err := fetchInstance(ctx, instances[i], resources, force, lockTimeout, opts); err != nil {
+					ui.Errorln(instances[i].Provider.Name + ": " + err.Error())
+					atomic.AddInt32(&failed, 1)
 				}
+			})
+			if reports != nil {
+				if err := writeReports(outputFile, reports.List()); err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				ui.Successf("\nFetch report has been written to %s\n", outputFile)
 			}
-			if errFlag {
+			if failed > 0 {
 				ui.Errorf(`
 This may be exception, view detailed exception in %s.`,
 					filepath.Join(global.WorkSpace(), "logs"))
-
+				return fmt.Errorf("%d of %d providers failed to fetch", failed, len(instances))
 			}
 			return nil
 		},
 	}
+	cmd.PersistentFlags().Int("provider-parallelism", 4, "the number of provider instances to fetch concurrently")
+	cmd.PersistentFlags().Bool("resume", false, "continue the pull interrupted last time, only the tables which did not finish are pulled")
+	cmd.PersistentFlags().String("output", outputTable, "the output format of the error report: table or json")
+	cmd.PersistentFlags().String("output-file", "", "the file to write the json report to, required by --output json since stdout is used by the progress of fetch")
+	cmd.PersistentFlags().StringSlice("provider", nil, "only fetch the provider instances of the names, or the instances of the providers")
+	cmd.PersistentFlags().StringSlice("resources", nil, "only fetch the resources instead of the resources in provider config, the other resources keep the data fetched last time")
+	cmd.PersistentFlags().Bool("force", false, "fetch the resources even if they are within their cache time")
+	cmd.PersistentFlags().Duration("lock-timeout", 0, "fail a provider if the lock of its schema is not acquired in time, such as 10m, 0 waits until the lock is released or its lease expires")
+	cmd.PersistentFlags().Bool("allow-partial", false, "replace the data fetched last time even if some tables did not finish, they are left empty or partially pulled")
 
 	cmd.SetHelpFunc(cmd.HelpFunc())
 	return cmd
 }
 
+// selectInstances return the instances of the providers, an instance is selected by its name or the name of its provider,
+// all the instances are returned if providers is empty
+func selectInstances(instances []tools.ProviderSchema, providers []string) ([]tools.ProviderSchema, error) {
+	if len(providers) == 0 {
+		return instances, nil
+	}
+	var selected []tools.ProviderSchema
+	var matched = make(map[string]bool)
+	for _, instance := range instances {
+		for _, name := range providers {
+			if name == instance.Provider.Name || name == instance.Decl.Name {
+				matched[name] = true
+				selected = append(selected, instance)
+				break
+			}
+		}
+	}
+	for _, name := range providers {
+		if !matched[name] {
+			return nil, fmt.Errorf("provider %s is not found in selefra config", name)
+		}
+	}
+	return selected, nil
+}
+
+// fetchInstance fetch the resources of a provider instance which are out of their cache time, or all of them if force,
+// resources replace the resources in provider config and the other resources keep the data fetched last time.
+// the instance holds the lock of its schema like sync does until its schema is swapped, so a concurrent fetch or apply
+// does not drop its staging schema, the fetch fails if the lock is not acquired within lockTimeout, zero waits without limit
+func fetchInstance(ctx context.Context, instance tools.ProviderSchema, resources []string, force bool, lockTimeout time.Duration, opts FetchOptions) error {
+	prvd := instance.Provider
+	if len(resources) > 0 {
+		prvd = tools.OverrideResources(prvd, resources)
+		opts.Tables = resources
+	}
+
+	store, diag := pgstorage.PgStorage(ctx, pgstorage.WithSearchPath(instance.Schema))
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer store.Close()
+	uuid := id_util.RandomId()
+	if err := pgstorage.AcquireLock(ctx, store, instance.Schema, pgstorage.NewLockHolder(uuid), lockTimeout); err != nil {
+		return fmt.Errorf("failed to lock %s: %s", instance.Schema, err.Error())
+	}
+	defer func() {
+		if err := pgstorage.ReleaseLock(context.Background(), store, instance.Schema, uuid); err != nil {
+			ui.Errorln(err.Error())
+		}
+	}()
+
+	if force {
+		return FetchWithOptions(ctx, instance.Decl, prvd, opts)
+	}
+	expiredTables, all, _ := tools.ExpiredTables(ctx, store, instance.Decl, prvd)
+	if !all && len(expiredTables) == 0 {
+		ui.Successf("%s %s@%s all ready use cache, fetch with --force to refresh it\n", prvd.Name, instance.Decl.Name, instance.Decl.Version)
+		return nil
+	}
+	if !all {
+		opts.Tables = expiredTables
+	}
+	return FetchWithOptions(ctx, instance.Decl, prvd, opts)
+}
+
+// FetchOptions change how the resources of a provider instance are fetched
+type FetchOptions struct {
+	// Progress show the pull of the instance as one of its bars, instances fetched concurrently share one progress
+	Progress *progress.Progress
+	// Resume continue the pull interrupted last time, only the tables which did not finish are pulled
+	Resume bool
+	// ResumeWithin only resume a pull started within the duration, zero means no limit
+	ResumeWithin time.Duration
+	// Reports collect the report of the instance instead of printing its errors, instances fetched concurrently may share one
+	Reports *Reports
+	// Tables only pull the tables, the other tables of the instance keep the data fetched last time,
+	// nil pulls the resources configured for the instance
+	Tables []string
+	// AllowPartial replace the schema of the instance even if some requested tables did not finish,
+	// otherwise the data fetched last time is kept and the finished tables can be resumed
+	AllowPartial bool
+}
+
 func Fetch(ctx context.Context, decl *config.ProviderDecl, prvd *config.Provider) error {
-	if decl.Path == "" {
-		decl.Path = utils.GetPathBySource(*decl.Source, decl.Version)
+	return FetchWithOptions(ctx, decl, prvd, FetchOptions{})
+}
+
+// FetchWithOptions fetch the resources of a provider instance into a staging schema, the schema of the instance
+// is replaced once the pull is finished. the finished tables are checkpointed, so an interrupted pull can be resumed.
+// the caller must hold the lock of the schema, the staging schema is shared by the runs fetching the instance
+func FetchWithOptions(ctx context.Context, decl *config.ProviderDecl, prvd *config.Provider, opts FetchOptions) (err error) {
+	progbar := opts.Progress
+	if progbar == nil {
+		progbar = progress.CreateProgress()
+	}
+	// decl is shared by the instances of a provider which may be fetched concurrently, it is not modified
+	pluginPath := decl.Path
+	if pluginPath == "" {
+		pluginPath = utils.GetPathBySource(*decl.Source, decl.Version)
 	}
 	var providersName = *decl.Source
 	ui.Successf("%s %s@%s pull infrastructure data:\n", prvd.Name, providersName, decl.Version)
 	ui.Print(fmt.Sprintf("Pulling %s@%s Please wait for resource information ...", providersName, decl.Version), false)
-	plug, err := plugin.NewManagedPlugin(decl.Path, providersName, decl.Version, "", nil)
+
+	// pull into a staging schema, the schema of provider is replaced only when the pull is finished
+	schemaKey := config.GetSchemaKey(decl, *prvd)
+	report := &FetchReport{Provider: prvd.Name, Schema: schemaKey, TableErrors: []*TableError{}, Metrics: []*TableMetric{}}
+	if opts.Reports != nil {
+		defer func() {
+			if err != nil {
+				report.Error = err.Error()
+			}
+			opts.Reports.add(report)
+		}()
+	}
+	var cp *checkpoint
+	if opts.Resume {
+		var err error
+		cp, err = readCheckpoint(ctx, schemaKey)
+		if err != nil {
+			return err
+		}
+		if cp != nil && opts.ResumeWithin > 0 && time.Since(cp.started) > opts.ResumeWithin {
+			cp = nil
+		}
+	}
+	if cp == nil {
+		if err := dropStagingSchema(ctx, schemaKey); err != nil {
+			return err
+		}
+	}
+	// the staging schema is kept to be resumed once a table is finished
+	keepStaging := cp != nil
+	pulled := false
+	defer func() {
+		if pulled || keepStaging {
+			return
+		}
+		if err := dropStagingSchema(context.Background(), schemaKey); err != nil {
+			ui.Errorln(err.Error())
+		}
+	}()
+
+	plug, err := plugin.NewManagedPlugin(pluginPath, providersName, decl.Version, "", nil)
 	if err != nil {
 		return err
 	}
 
-	storageOpt := pgstorage.DefaultPgStorageOpts()
-	pgstorage.WithSearchPath(config.GetSchemaKey(decl, *prvd))(storageOpt)
+	storageOpt, err := pgstorage.DefaultPgStorageOpts()
+	if err != nil {
+		return err
+	}
+	pgstorage.WithSearchPath(stagingSchema(schemaKey))(storageOpt)
 
 	opt, err := json.Marshal(storageOpt)
 	if err != nil {
 		return err
 	}
 
-	prvdByte, err := yaml.Marshal(prvd)
+	// the cache times of resources are used by selefra, the plugin only needs the table names
+	pluginConfig := *prvd
+	pluginConfig.Resources = nil
+	for _, name := range prvd.ResourceNames() {
+		pluginConfig.Resources = append(pluginConfig.Resources, config.ProviderResource{Name: name})
+	}
+	prvdByte, err := yaml.Marshal(pluginConfig)
 	if err != nil {
 		return err
 	}
@@ -107,16 +298,44 @@ func Fetch(ctx context.Context, decl *config.ProviderDecl, prvd *config.Provider
 	}
 
 	defer plug.Close()
-	dropRes, err := plugProvider.DropTableAll(ctx, &shard.ProviderDropTableAllRequest{})
+	var tables []string
+	switch {
+	case opts.Tables != nil:
+		tables = opts.Tables
+	case len(prvd.Resources) == 0:
+		tables = append(tables, "*")
+	default:
+		tables = prvd.ResourceNames()
+	}
+
+	roots, err := providerTables(ctx, plugProvider)
 	if err != nil {
-		ui.Errorln(err.Error())
 		return err
 	}
-	if dropRes.Diagnostics != nil {
-		err := ui.PrintDiagnostic(dropRes.Diagnostics.GetDiagnosticSlice())
+
+	fetchTime := time.Now()
+	if cp == nil {
+		dropRes, err := plugProvider.DropTableAll(ctx, &shard.ProviderDropTableAllRequest{})
 		if err != nil {
-			return errors.New("fetch plugProvider drop table error")
+			ui.Errorln(err.Error())
+			return err
 		}
+		if dropRes.Diagnostics != nil {
+			err := ui.PrintDiagnostic(dropRes.Diagnostics.GetDiagnosticSlice())
+			if err != nil {
+				return errors.New("fetch plugProvider drop table error")
+			}
+		}
+	} else {
+		// the data of a resumed pull is as old as the interrupted pull
+		fetchTime = cp.started
+		var dropTables []string
+		tables, dropTables = resumePlan(roots, tables, cp.finished)
+		if err := dropStagingTables(ctx, schemaKey, dropTables); err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		ui.Successf("Resume the pull started at %s, %d tables finished, %d tables left\n", cp.started.Local().Format(time.RFC3339), len(cp.finished), len(tables))
 	}
 
 	createRes, err := plugProvider.CreateAllTables(ctx, &shard.ProviderCreateAllTablesRequest{})
@@ -130,59 +349,142 @@ func Fetch(ctx context.Context, decl *config.ProviderDecl, prvd *config.Provider
 			return errors.New("fetch plugProvider create table error")
 		}
 	}
-	var tables []string
-	resources := prvd.Resources
-	if len(resources) == 0 {
-		tables = append(tables, "*")
-	} else {
-		for i := range resources {
-			tables = append(tables, resources[i])
+	if cp == nil && opts.Tables != nil {
+		// the tables not pulled keep the data fetched last time
+		if err := copyLiveTables(ctx, schemaKey, keptTables(roots, tables)); err != nil {
+			ui.Errorln(err.Error())
+			return err
 		}
 	}
+
+	// the checkpoints are kept in the key values of the staging schema
+	checkpointStore, diag := pgstorage.PgStorage(ctx, pgstorage.WithSearchPath(stagingSchema(schemaKey)))
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer checkpointStore.Close()
+	if cp == nil {
+		if err := pgstorage.SetStorageValue(ctx, checkpointStore, checkpointStartedKey, fetchTime.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+
 	var maxGoroutines uint64 = 100
 	if prvd.MaxGoroutines > 0 {
 		maxGoroutines = prvd.MaxGoroutines
 	}
-	recv, err := plugProvider.PullTables(ctx, &shard.PullTablesRequest{
-		Tables:        tables,
-		MaxGoroutines: maxGoroutines,
-		Timeout:       0,
-	})
-	if err != nil {
-		ui.Errorln(err.Error())
-		return err
-	}
-	progbar := progress.CreateProgress()
-	progbar.Add(decl.Name+"@"+decl.Version, -1)
-	success := 0
-	errorsN := 0
-	var total int64
-	for {
-		res, err := recv.Recv()
+	var metrics []*TableMetric
+	if len(tables) > 0 {
+		pullStart := time.Now()
+		recv, err := plugProvider.PullTables(ctx, &shard.PullTablesRequest{
+			Tables:        tables,
+			MaxGoroutines: maxGoroutines,
+			Timeout:       0,
+		})
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				progbar.Current(decl.Name+"@"+decl.Version, total, "Done")
-				progbar.Done(decl.Name + "@" + decl.Version)
-				break
-			}
+			ui.Errorln(err.Error())
 			return err
 		}
-		progbar.SetTotal(decl.Name+"@"+decl.Version, int64(res.TableCount))
-		progbar.Current(decl.Name+"@"+decl.Version, int64(len(res.FinishedTables)), res.Table)
-		total = int64(res.TableCount)
-		if res.Diagnostics != nil {
-			if res.Diagnostics.HasError() {
-				ui.SaveLogToDiagnostic(res.Diagnostics.GetDiagnosticSlice())
+		barName := prvd.Name + " " + decl.Name + "@" + decl.Version
+		progbar.Add(barName, -1)
+		success := 0
+		collector := newErrorCollector()
+		var startedAt = make(map[string]time.Time)
+		var finishedAt = make(map[string]time.Time)
+		var finishedTables map[string]bool
+		var total int64
+		for {
+			res, err := recv.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					progbar.Current(barName, total, "Done")
+					progbar.Done(barName)
+					break
+				}
+				progbar.Abort(barName)
+				if keepStaging {
+					ui.Errorf("%s pull interrupted, run selefra fetch --resume to pull the tables not finished\n", prvd.Name)
+				}
+				return err
+			}
+			progbar.SetTotal(barName, int64(res.TableCount))
+			progbar.Current(barName, int64(len(res.FinishedTables)), res.Table)
+			total = int64(res.TableCount)
+			if _, ok := startedAt[res.Table]; res.Table != "" && !ok && !res.FinishedTables[res.Table] {
+				startedAt[res.Table] = time.Now()
+			}
+			if res.Diagnostics != nil {
+				if res.Diagnostics.HasError() {
+					ui.SaveLogToDiagnostic(res.Diagnostics.GetDiagnosticSlice())
+				}
+				collector.add(res.Table, res.Diagnostics.GetDiagnosticSlice())
+			}
+			if res.Table != "" && res.FinishedTables[res.Table] {
+				finishedAt[res.Table] = time.Now()
+				if err := pgstorage.SetStorageValue(ctx, checkpointStore, checkpointKey(res.Table), time.Now().Format(time.RFC3339)); err != nil {
+					ui.Errorln(err.Error())
+				} else {
+					keepStaging = true
+				}
 			}
+			success = len(res.FinishedTables)
+			finishedTables = res.FinishedTables
+		}
+		pullEnd := time.Now()
+		progbar.Wait(barName)
+		report.Tables = success
+		report.Errors = collector.total()
+		report.TableErrors = collector.list()
+		if err := markPartial(ctx, schemaKey, report.TableErrors); err != nil {
+			ui.Errorln(err.Error())
+		}
+		metrics = tableMetrics(roots, pullStart, pullEnd, startedAt, finishedAt, report.TableErrors)
+		if err := countRows(ctx, schemaKey, roots, metrics); err != nil {
+			ui.Errorln(err.Error())
+		}
+		report.Metrics = metrics
+		if report.Errors > 0 {
+			ui.Errorf("\nPull complete! Total Resources pulled:%d        Errors: %d\n", success, report.Errors)
+		} else {
+			ui.Successf("\nPull complete! Total Resources pulled:%d        Errors: %d\n", success, report.Errors)
+		}
+		if opts.Reports == nil {
+			printTableMetrics(prvd.Name, metrics)
+			printTableErrors(prvd.Name, report.TableErrors)
+		}
+		if unfinished := unfinishedRoots(roots, tables, finishedTables); len(unfinished) > 0 && !opts.AllowPartial {
+			return fmt.Errorf("tables %s did not finish, the data fetched last time is kept, fetch with --resume to pull them again or with --allow-partial to use the partial data",
+				strings.Join(unfinished, ", "))
 		}
-		success = len(res.FinishedTables)
-		errorsN = 0
 	}
-	progbar.Wait(decl.Name + "@" + decl.Version)
-	if errorsN > 0 {
-		ui.Errorf("\nPull complete! Total Resources pulled:%d        Errors: %d\n", success, errorsN)
-		return nil
+	if err := saveTableErrors(ctx, schemaKey, fetchTime, report.TableErrors); err != nil {
+		ui.Errorln(err.Error())
+		return err
 	}
-	ui.Successf("\nPull complete! Total Resources pulled:%d        Errors: %d\n", success, errorsN)
+	if err := saveFetchRun(ctx, schemaKey, fetchTime, maxGoroutines, metrics); err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	if err := swapSchema(ctx, schemaKey, fetchTime, prvd.Snapshots); err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	pulled = true
 	return nil
 }
+
+// providerTables return the root tables of the provider
+func providerTables(ctx context.Context, plugProvider shard.ProviderClient) (map[string]*schema.Table, error) {
+	infoRes, err := plugProvider.GetProviderInformation(ctx, &shard.GetProviderInformationRequest{})
+	if err != nil {
+		ui.Errorln(err.Error())
+		return nil, err
+	}
+	if infoRes.Diagnostics != nil {
+		err := ui.PrintDiagnostic(infoRes.Diagnostics.GetDiagnosticSlice())
+		if err != nil {
+			return nil, errors.New("fetch plugProvider get information error")
+		}
+	}
+	return infoRes.Tables, nil
+}
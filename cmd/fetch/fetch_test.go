@@ -5,6 +5,7 @@ import (
 	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -17,15 +18,33 @@ func TestFetch(t *testing.T) {
 	}
 
 	for _, p := range bootstrap.Selefra.ProviderDecls {
-		confs, err := tools.ProviderConfigStrs(bootstrap, p.Name)
-		if err != nil {
-			t.Fatal(err)
-		}
-		for _, conf := range confs {
-			err = Fetch(ctx, p, conf)
+		for _, prvd := range tools.ProvidersByID(bootstrap, p.Name) {
+			err = Fetch(ctx, p, prvd)
 			if err != nil {
 				t.Error(err)
 			}
 		}
 	}
 }
+
+func TestSelectInstances(t *testing.T) {
+	aws := &config.ProviderDecl{Name: "aws"}
+	instances := []tools.ProviderSchema{
+		{Schema: "aws_01", Decl: aws, Provider: &config.Provider{Name: "aws_01"}},
+		{Schema: "aws_02", Decl: aws, Provider: &config.Provider{Name: "aws_02"}},
+		{Schema: "gcp_01", Decl: &config.ProviderDecl{Name: "gcp"}, Provider: &config.Provider{Name: "gcp_01"}},
+	}
+
+	selected, err := selectInstances(instances, nil)
+	require.NoError(t, err)
+	require.Len(t, selected, 3)
+
+	selected, err = selectInstances(instances, []string{"aws_02", "gcp"})
+	require.NoError(t, err)
+	require.Len(t, selected, 2)
+	require.Equal(t, "aws_02", selected[0].Schema)
+	require.Equal(t, "gcp_01", selected[1].Schema)
+
+	_, err = selectInstances(instances, []string{"azure"})
+	require.Error(t, err)
+}
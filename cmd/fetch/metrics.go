@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// fetchRunsTable is the table in a provider schema which keeps the metrics of the tables pulled by the latest fetches
+const fetchRunsTable = "selefra_fetch_runs"
+
+// fetchRunsRetained is the number of fetches whose metrics are kept
+const fetchRunsRetained = 20
+
+// slowestTables is the number of tables shown in the summary after a pull
+const slowestTables = 10
+
+// TableMetric is the metric of a root table in a pull, the rows and errors of its sub tables are counted for it
+type TableMetric struct {
+	Table      string    `json:"table"`
+	Rows       int64     `json:"rows"`
+	Errors     int       `json:"errors"`
+	DurationMs int64     `json:"duration_ms"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Finished is false if the table did not finish, its duration lasts to the end of the pull
+	Finished bool `json:"finished"`
+}
+
+// tableMetrics return the metrics of the root tables in a pull from pullStart to pullEnd, the slowest first.
+// a root table starts when it or one of its sub tables is first named by the pull before it finished, or at pullStart
+// if none is, the root tables which did not finish are included if they have errors
+func tableMetrics(roots map[string]*schema.Table, pullStart, pullEnd time.Time, startedAt, finishedAt map[string]time.Time, tableErrors []*TableError) []*TableMetric {
+	var rootOf = make(map[string]string)
+	for name, root := range roots {
+		for _, t := range flatTable(root) {
+			rootOf[t] = name
+		}
+	}
+	var starts = make(map[string]time.Time)
+	for name, started := range startedAt {
+		root, ok := rootOf[name]
+		if !ok {
+			continue
+		}
+		if start, ok := starts[root]; !ok || started.Before(start) {
+			starts[root] = started
+		}
+	}
+	var errorsOf = make(map[string]int)
+	for _, te := range tableErrors {
+		if root, ok := rootOf[te.Table]; ok {
+			errorsOf[root] += te.Errors
+		}
+	}
+
+	var metrics = make([]*TableMetric, 0, len(finishedAt))
+	for name := range roots {
+		finished, ok := finishedAt[name]
+		if !ok && errorsOf[name] == 0 {
+			continue
+		}
+		m := &TableMetric{Table: name, Errors: errorsOf[name], StartedAt: pullStart, FinishedAt: finished, Finished: ok}
+		if start, ok := starts[name]; ok {
+			m.StartedAt = start
+		}
+		end := pullEnd
+		if m.Finished {
+			end = finished
+		}
+		m.DurationMs = end.Sub(m.StartedAt).Milliseconds()
+		metrics = append(metrics, m)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].DurationMs != metrics[j].DurationMs {
+			return metrics[i].DurationMs > metrics[j].DurationMs
+		}
+		return metrics[i].Table < metrics[j].Table
+	})
+	return metrics
+}
+
+// countRows set the rows of the metrics to the rows of their tables in the staging schema of schema
+func countRows(ctx context.Context, schema string, roots map[string]*schema.Table, metrics []*TableMetric) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	for _, m := range metrics {
+		tables := flatTable(roots[m.Table])
+		if len(tables) == 0 {
+			tables = []string{m.Table}
+		}
+		for _, t := range tables {
+			res, diag := sto.Query(ctx, fmt.Sprintf("SELECT count(*) FROM %s.%s", stagingSchema(schema), t))
+			if diag != nil && diag.HasError() {
+				// the table is not created by provider
+				continue
+			}
+			rows, diag := res.ReadRows(-1)
+			if diag != nil && diag.HasError() {
+				return errors.New(diag.ToString())
+			}
+			for _, row := range rows.GetMatrix() {
+				n, _ := strconv.ParseInt(utils.Strava(row[0]), 10, 64)
+				m.Rows += n
+			}
+		}
+	}
+	return nil
+}
+
+// saveFetchRun write the metrics of a fetch into the staging schema of schema, the metrics of the latest fetches
+// are carried over from schema
+func saveFetchRun(ctx context.Context, schema string, fetchTime time.Time, maxGoroutines uint64, metrics []*TableMetric) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	runsTable := stagingSchema(schema) + "." + fetchRunsTable
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (run_id text, table_name text, rows bigint, errors integer, duration_ms bigint, max_goroutines bigint, started_at timestamptz, finished_at timestamptz);
+DELETE FROM %[1]s;
+DO $$ BEGIN
+	IF to_regclass('%[2]s.%[3]s') IS NOT NULL THEN
+		INSERT INTO %[1]s SELECT * FROM %[2]s.%[3]s;
+	END IF;
+END $$;`, runsTable, schema, fetchRunsTable)
+	if diag := sto.Exec(ctx, sql); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+
+	runId := snapshot.Id(fetchTime)
+	for _, m := range metrics {
+		// finished_at is null for a table which did not finish
+		var finishedAt interface{}
+		if m.Finished {
+			finishedAt = m.FinishedAt
+		}
+		diag := sto.Exec(ctx, fmt.Sprintf("INSERT INTO %s (run_id, table_name, rows, errors, duration_ms, max_goroutines, started_at, finished_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", runsTable),
+			runId, m.Table, m.Rows, m.Errors, m.DurationMs, int64(maxGoroutines), m.StartedAt, finishedAt)
+		if diag != nil && diag.HasError() {
+			return errors.New(diag.ToString())
+		}
+	}
+
+	diag = sto.Exec(ctx, fmt.Sprintf("DELETE FROM %[1]s WHERE run_id NOT IN (SELECT DISTINCT run_id FROM %[1]s ORDER BY run_id DESC LIMIT %[2]d)", runsTable, fetchRunsRetained))
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
+
+// printTableMetrics show the slowest tables of a provider instance as a table
+func printTableMetrics(provider string, metrics []*TableMetric) {
+	if len(metrics) == 0 {
+		return
+	}
+	shown := metrics
+	if len(shown) > slowestTables {
+		shown = shown[:slowestTables]
+	}
+	ui.Successf("\n%s slowest tables of %d, see %s for all:\n", provider, len(metrics), fetchRunsTable)
+	var body [][]string
+	for _, m := range shown {
+		duration := (time.Duration(m.DurationMs) * time.Millisecond).String()
+		if !m.Finished {
+			duration += " (unfinished)"
+		}
+		body = append(body, []string{m.Table, strconv.FormatInt(m.Rows, 10), strconv.Itoa(m.Errors), duration})
+	}
+	table.ShowTable([]string{"Table", "Rows", "Errors", "Duration"}, body, []string{}, true)
+}
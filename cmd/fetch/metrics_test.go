@@ -0,0 +1,60 @@
+package fetch
+
+import (
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTableMetrics(t *testing.T) {
+	roots := map[string]*schema.Table{
+		"aws_s3_buckets": {
+			TableName: "aws_s3_buckets",
+			SubTables: []*schema.Table{
+				{TableName: "aws_s3_bucket_grants"},
+			},
+		},
+		"aws_ec2_instances": {TableName: "aws_ec2_instances"},
+	}
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Second)
+	finishedAt := map[string]time.Time{
+		"aws_ec2_instances": start.Add(2 * time.Second),
+		"aws_s3_buckets":    start.Add(5 * time.Second),
+	}
+	startedAt := map[string]time.Time{
+		"aws_s3_bucket_grants": start.Add(3 * time.Second),
+		"aws_s3_buckets":       start.Add(time.Second),
+		"gcp_unknown":          start,
+	}
+	tableErrors := []*TableError{
+		{Table: "aws_s3_bucket_grants", Errors: 2},
+		{Table: "aws_s3_buckets", Errors: 1},
+		{Table: "", Errors: 3},
+	}
+
+	metrics := tableMetrics(roots, start, end, startedAt, finishedAt, tableErrors)
+	require.Len(t, metrics, 2)
+	require.Equal(t, "aws_s3_buckets", metrics[0].Table)
+	require.Equal(t, int64(4000), metrics[0].DurationMs)
+	require.Equal(t, start.Add(time.Second), metrics[0].StartedAt)
+	require.Equal(t, 3, metrics[0].Errors)
+	require.True(t, metrics[0].Finished)
+	require.Equal(t, "aws_ec2_instances", metrics[1].Table)
+	require.Equal(t, int64(2000), metrics[1].DurationMs)
+	require.Equal(t, 0, metrics[1].Errors)
+	require.Equal(t, start, metrics[1].StartedAt)
+
+	require.Empty(t, tableMetrics(roots, start, end, nil, nil, nil))
+
+	// a table which did not finish is measured to the end of the pull if it has errors
+	metrics = tableMetrics(roots, start, end, startedAt, map[string]time.Time{"aws_ec2_instances": start.Add(2 * time.Second)}, tableErrors)
+	require.Len(t, metrics, 2)
+	require.Equal(t, "aws_s3_buckets", metrics[0].Table)
+	require.False(t, metrics[0].Finished)
+	require.True(t, metrics[0].FinishedAt.IsZero())
+	require.Equal(t, int64(9000), metrics[0].DurationMs)
+	require.Equal(t, 3, metrics[0].Errors)
+	require.Len(t, tableMetrics(roots, start, end, nil, nil, []*TableError{{Table: "aws_s3_buckets", Errors: 1}}), 1)
+}
@@ -0,0 +1,223 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// fetchErrorsTable is the table in a provider schema which keeps the table errors of the fetch of its data
+const fetchErrorsTable = "selefra_fetch_errors"
+
+const (
+	// CategoryPermission is an error caused by the credentials of provider lacking a permission
+	CategoryPermission = "permission"
+	// CategoryPanic is an error caused by a panic in provider, it is likely a bug of provider
+	CategoryPanic = "panic"
+	// CategoryError is any other error
+	CategoryError = "error"
+)
+
+// permissionCodes is the error codes of the clouds for a request denied for the lack of a permission,
+// such as AccessDenied and AccessDeniedException of aws and alicloud, UnauthorizedOperation of aws ec2,
+// AuthorizationFailed of azure and PERMISSION_DENIED of gcp
+var permissionCodes = []string{"AccessDenied", "UnauthorizedOperation", "AuthorizationFailed", "PERMISSION_DENIED"}
+
+// panicMessage is in the diagnostic of a panic recovered by the provider sdk
+const panicMessage = "pull table panic"
+
+// TableError is the errors of a table in the fetch of a provider instance
+type TableError struct {
+	Table      string `json:"table"`
+	Errors     int    `json:"errors"`
+	Category   string `json:"category"`
+	FirstError string `json:"first_error"`
+	// Partial is true if some rows of the table were written despite the errors
+	Partial bool `json:"partial"`
+}
+
+// FetchReport is the result of the fetch of a provider instance
+type FetchReport struct {
+	Provider    string        `json:"provider"`
+	Schema      string        `json:"schema"`
+	Tables      int           `json:"tables"`
+	Errors      int           `json:"errors"`
+	TableErrors []*TableError `json:"table_errors"`
+	// Metrics is the metrics of the root tables pulled, the slowest first
+	Metrics []*TableMetric `json:"metrics"`
+	// Error is the error which failed the fetch
+	Error string `json:"error,omitempty"`
+}
+
+// Reports collect the reports of the fetched provider instances, it is safe for concurrent use
+type Reports struct {
+	lock    sync.Mutex
+	reports []*FetchReport
+}
+
+func (r *Reports) add(report *FetchReport) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+// List return the reports sorted by provider
+func (r *Reports) List() []*FetchReport {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	reports := append([]*FetchReport{}, r.reports...)
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Provider < reports[j].Provider
+	})
+	return reports
+}
+
+// writeReports write the reports into the file of path as json
+func writeReports(path string, reports []*FetchReport) error {
+	b, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// errorCollector aggregate the error diagnostics of a pull by table
+type errorCollector struct {
+	tables map[string]*TableError
+}
+
+func newErrorCollector() *errorCollector {
+	return &errorCollector{tables: make(map[string]*TableError)}
+}
+
+// add count the error diagnostics of table, which is the table of the pull response carrying them,
+// the diagnostics of a response without a table are counted for the empty table
+func (c *errorCollector) add(table string, diagnostics []*schema.Diagnostic) {
+	for _, d := range diagnostics {
+		if d.Level() < schema.DiagnosisLevelError {
+			continue
+		}
+		te, ok := c.tables[table]
+		if !ok {
+			te = &TableError{Table: table, Category: errorCategory(d.Content()), FirstError: d.Content()}
+			c.tables[table] = te
+		}
+		te.Errors++
+	}
+}
+
+// total return the number of errors of all tables
+func (c *errorCollector) total() int {
+	var n int
+	for _, te := range c.tables {
+		n += te.Errors
+	}
+	return n
+}
+
+// list return the table errors sorted by table
+func (c *errorCollector) list() []*TableError {
+	var list = make([]*TableError, 0, len(c.tables))
+	for _, te := range c.tables {
+		list = append(list, te)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Table < list[j].Table
+	})
+	return list
+}
+
+// errorCategory tell an error of missing permission or a panic of provider from other errors
+func errorCategory(content string) string {
+	for _, code := range permissionCodes {
+		if strings.Contains(content, code) {
+			return CategoryPermission
+		}
+	}
+	if strings.Contains(content, panicMessage) {
+		return CategoryPanic
+	}
+	return CategoryError
+}
+
+// markPartial set Partial of the table errors whose table has rows in the staging schema of schema
+func markPartial(ctx context.Context, schema string, tableErrors []*TableError) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	for _, te := range tableErrors {
+		if te.Table == "" {
+			continue
+		}
+		res, diag := sto.Query(ctx, fmt.Sprintf("SELECT 1 FROM %s.%s LIMIT 1", stagingSchema(schema), te.Table))
+		if diag != nil && diag.HasError() {
+			// the name is not a table of provider
+			continue
+		}
+		rows, diag := res.ReadRows(-1)
+		if diag != nil && diag.HasError() {
+			return errors.New(diag.ToString())
+		}
+		te.Partial = len(rows.GetMatrix()) > 0
+	}
+	return nil
+}
+
+// saveTableErrors write the table errors into the staging schema of schema, they are swapped in with the data
+func saveTableErrors(ctx context.Context, schema string, fetchTime time.Time, tableErrors []*TableError) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	errorsTable := stagingSchema(schema) + "." + fetchErrorsTable
+	if diag := sto.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (table_name text, errors integer, category text, first_error text, partial boolean, fetched_at timestamptz);
+DELETE FROM %[1]s;`, errorsTable)); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	for _, te := range tableErrors {
+		diag := sto.Exec(ctx, fmt.Sprintf("INSERT INTO %s (table_name, errors, category, first_error, partial, fetched_at) VALUES ($1, $2, $3, $4, $5, $6)", errorsTable),
+			te.Table, te.Errors, te.Category, te.FirstError, te.Partial, fetchTime)
+		if diag != nil && diag.HasError() {
+			return errors.New(diag.ToString())
+		}
+	}
+	return nil
+}
+
+// printTableErrors show the table errors of a provider instance as a table
+func printTableErrors(provider string, tableErrors []*TableError) {
+	if len(tableErrors) == 0 {
+		return
+	}
+	ui.Errorf("\n%s errors by table:\n", provider)
+	var body [][]string
+	for _, te := range tableErrors {
+		name := te.Table
+		if name == "" {
+			name = "-"
+		}
+		body = append(body, []string{name, strconv.Itoa(te.Errors), te.Category, strconv.FormatBool(te.Partial), utils.Truncate(te.FirstError, 100)})
+	}
+	table.ShowTable([]string{"Table", "Errors", "Category", "Partial", "First Error"}, body, []string{}, true)
+}
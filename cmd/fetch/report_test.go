@@ -0,0 +1,61 @@
+package fetch
+
+import (
+	"encoding/json"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorCollector(t *testing.T) {
+	collector := newErrorCollector()
+	collector.add("aws_s3_buckets", schema.NewDiagnostics().
+		AddErrorMsg("operation error S3: ListBuckets, api error AccessDenied: Access Denied").
+		AddErrorMsg("operation error S3: ListBuckets, timeout").
+		AddWarn("throttled").
+		GetDiagnosticSlice())
+	collector.add("aws_ec2_instances", schema.NewDiagnostics().
+		AddErrorMsg("taskId = 1, table aws_ec2_instances data source pull table panic: nil pointer").
+		GetDiagnosticSlice())
+	collector.add("aws_iam_users", schema.NewDiagnostics().
+		AddErrorMsg("you are not authorized to perform this operation").
+		GetDiagnosticSlice())
+	collector.add("", schema.NewDiagnostics().
+		AddErrorMsg("table aws_rds_instances: connection reset").
+		GetDiagnosticSlice())
+
+	require.Equal(t, 5, collector.total())
+	list := collector.list()
+	require.Len(t, list, 4)
+	require.Equal(t, &TableError{Table: "", Errors: 1, Category: CategoryError, FirstError: "table aws_rds_instances: connection reset"}, list[0])
+	require.Equal(t, "aws_ec2_instances", list[1].Table)
+	require.Equal(t, CategoryPanic, list[1].Category)
+	require.Equal(t, "aws_iam_users", list[2].Table)
+	require.Equal(t, CategoryError, list[2].Category)
+	require.Equal(t, "aws_s3_buckets", list[3].Table)
+	require.Equal(t, 2, list[3].Errors)
+	require.Equal(t, CategoryPermission, list[3].Category)
+	require.Equal(t, "operation error S3: ListBuckets, api error AccessDenied: Access Denied", list[3].FirstError)
+}
+
+func TestErrorCategory(t *testing.T) {
+	require.Equal(t, CategoryPermission, errorCategory("api error UnauthorizedOperation: You are not authorized"))
+	require.Equal(t, CategoryPermission, errorCategory("AccessDeniedException: User is not authorized"))
+	require.Equal(t, CategoryPermission, errorCategory("Code=\"AuthorizationFailed\" Message=\"The client does not have authorization\""))
+	require.Equal(t, CategoryPermission, errorCategory("rpc error: code = PermissionDenied desc = PERMISSION_DENIED"))
+	require.Equal(t, CategoryError, errorCategory("table permissions_boundary not found"))
+}
+
+func TestWriteReports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, writeReports(path, []*FetchReport{{Provider: "aws", Schema: "aws_public", Errors: 1}}))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var reports []*FetchReport
+	require.NoError(t, json.Unmarshal(b, &reports))
+	require.Len(t, reports, 1)
+	require.Equal(t, "aws", reports[0].Provider)
+	require.Equal(t, 1, reports[0].Errors)
+}
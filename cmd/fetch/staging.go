@@ -0,0 +1,179 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/pkg/utils"
+	"strings"
+	"time"
+)
+
+const (
+	stagingSuffix = "_staging"
+	retiredSuffix = "_retired"
+)
+
+// stagingSchema return the schema a fetch pull data into, it replaces schema once the pull is finished,
+// so readers of schema never see a half-populated schema
+func stagingSchema(schema string) string {
+	return snapshot.DerivedSchema(schema, stagingSuffix)
+}
+
+func retiredSchema(schema string) string {
+	return snapshot.DerivedSchema(schema, retiredSuffix)
+}
+
+// swapSchemaSql build the statements which replace schema with its staging schema in a single transaction,
+// the key values of schema such as locks and cache time are carried over to the new schema, the checkpoints of the pull
+// become the fetch times of the pulled tables and are removed.
+// the replaced schema is kept as snapshotSchema, it is dropped if snapshotSchema is empty
+func swapSchemaSql(schema, snapshotSchema string, fetchTime time.Time) string {
+	staging := stagingSchema(schema)
+	retired := snapshotSchema
+	if retired == "" {
+		retired = retiredSchema(schema)
+	}
+	sql := fmt.Sprintf(`BEGIN;
+CREATE SCHEMA IF NOT EXISTS %[1]s;
+CREATE TABLE IF NOT EXISTS %[1]s.selefra_meta_kv ("key" text UNIQUE, value text);
+CREATE TABLE IF NOT EXISTS %[2]s.selefra_meta_kv ("key" text UNIQUE, value text);
+LOCK TABLE %[1]s.selefra_meta_kv IN EXCLUSIVE MODE;
+INSERT INTO %[2]s.selefra_meta_kv ("key", value) SELECT "key", value FROM %[1]s.selefra_meta_kv
+	ON CONFLICT ("key") DO UPDATE SET value = EXCLUDED.value;
+INSERT INTO %[2]s.selefra_meta_kv ("key", value) VALUES ('%[4]s', '%[5]s')
+	ON CONFLICT ("key") DO UPDATE SET value = EXCLUDED.value;
+INSERT INTO %[2]s.selefra_meta_kv ("key", value) SELECT '%[8]s' || substr("key", %[9]d), value FROM %[2]s.selefra_meta_kv WHERE "key" LIKE '%[7]s%%'
+	ON CONFLICT ("key") DO UPDATE SET value = EXCLUDED.value;
+DELETE FROM %[2]s.selefra_meta_kv WHERE "key" = '%[6]s' OR "key" LIKE '%[7]s%%';
+DROP SCHEMA IF EXISTS %[3]s CASCADE;
+ALTER SCHEMA %[1]s RENAME TO %[3]s;
+ALTER SCHEMA %[2]s RENAME TO %[1]s;
+`, schema, staging, retired, snapshot.FetchTimeKey, fetchTime.Format(time.RFC3339), checkpointStartedKey, checkpointKeyPrefix,
+		snapshot.TableFetchTimeKeyPrefix, len(checkpointKeyPrefix)+1)
+	if snapshotSchema == "" {
+		sql += fmt.Sprintf("DROP SCHEMA %s CASCADE;\n", retired)
+	}
+	return sql + "COMMIT;"
+}
+
+func dropSchemaSql(schema string) string {
+	return fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema)
+}
+
+// swapSchema replace schema with the data pulled into its staging schema at fetchTime, the newest keep
+// replaced schemas are retained as snapshots
+func swapSchema(ctx context.Context, schema string, fetchTime time.Time, keep int) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	var snapshotSchema string
+	if keep > 0 {
+		t, ok, err := snapshot.FetchTime(ctx, sto, schema)
+		if err != nil {
+			return err
+		}
+		if ok {
+			snapshotSchema = snapshot.SchemaName(schema, snapshot.Id(t))
+		}
+	}
+	if diag := sto.Exec(ctx, swapSchemaSql(schema, snapshotSchema, fetchTime)); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	if _, err := snapshot.Prune(ctx, sto, schema, keep); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dropStagingSchema drop the staging schema of schema, the data of schema is left untouched.
+// it is called with the lock of schema held, otherwise it would drop the staging schema of another run
+func dropStagingSchema(ctx context.Context, schema string) error {
+	return execSql(ctx, dropSchemaSql(stagingSchema(schema)))
+}
+
+// dropStagingTables drop the tables of the staging schema of schema
+func dropStagingTables(ctx context.Context, schema string, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	var names []string
+	for _, table := range tables {
+		names = append(names, stagingSchema(schema)+"."+table)
+	}
+	return execSql(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", strings.Join(names, ", ")))
+}
+
+// copyLiveTables copy the rows of tables from schema into its staging schema, the tables not in schema are skipped,
+// only the columns in both schemas are copied in case the provider upgraded changes the columns of a table.
+// the staging schema replaces schema as a whole, so a partial pull copies every table it does not pull, the cost
+// grows with the rows of those tables rather than the rows pulled
+func copyLiveTables(ctx context.Context, schema string, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	staging := stagingSchema(schema)
+	res, diag := sto.Query(ctx, `SELECT table_schema, table_name, column_name FROM information_schema.columns
+	WHERE table_schema = $1 OR table_schema = $2 ORDER BY ordinal_position`, schema, staging)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	var columns = map[string]map[string][]string{schema: {}, staging: {}}
+	for _, row := range rows.GetMatrix() {
+		s, table, column := utils.Strava(row[0]), utils.Strava(row[1]), utils.Strava(row[2])
+		columns[s][table] = append(columns[s][table], column)
+	}
+
+	var sqls []string
+	for _, table := range tables {
+		var liveColumns = make(map[string]bool)
+		for _, column := range columns[schema][table] {
+			liveColumns[column] = true
+		}
+		var copied []string
+		for _, column := range columns[staging][table] {
+			if liveColumns[column] {
+				copied = append(copied, fmt.Sprintf("%q", column))
+			}
+		}
+		if len(copied) == 0 {
+			continue
+		}
+		list := strings.Join(copied, ", ")
+		sqls = append(sqls, fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s;", staging, table, list, list, schema, table))
+	}
+	if len(sqls) == 0 {
+		return nil
+	}
+	if diag := sto.Exec(ctx, strings.Join(sqls, "\n")); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
+
+func execSql(ctx context.Context, sql string) error {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	if diag := sto.Exec(ctx, sql); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package fetch
+
+import (
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwapSchemaSql(t *testing.T) {
+	fetchTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sql := swapSchemaSql("aws_01", "", fetchTime)
+	require.True(t, strings.HasPrefix(sql, "BEGIN;\n"))
+	require.True(t, strings.HasSuffix(sql, "COMMIT;"))
+	statements := strings.Split(strings.TrimSuffix(sql, "COMMIT;"), ";\n")
+	require.Equal(t, "ALTER SCHEMA aws_01 RENAME TO aws_01_retired", statements[len(statements)-4])
+	require.Equal(t, "ALTER SCHEMA aws_01_staging RENAME TO aws_01", statements[len(statements)-3])
+	require.Equal(t, "DROP SCHEMA aws_01_retired CASCADE", statements[len(statements)-2])
+	require.Contains(t, sql, "LOCK TABLE aws_01.selefra_meta_kv IN EXCLUSIVE MODE;")
+	require.Contains(t, sql, "VALUES ('"+snapshot.FetchTimeKey+"', '2023-01-02T03:04:05Z')")
+	require.Contains(t, sql, `DELETE FROM aws_01_staging.selefra_meta_kv WHERE "key" = 'checkpoint_started' OR "key" LIKE 'checkpoint:%';`)
+	require.Contains(t, sql, `SELECT '`+snapshot.TableFetchTimeKeyPrefix+`' || substr("key", 12), value`)
+	// the key values are carried over before the schemas are renamed
+	require.Less(t, strings.Index(sql, "INSERT INTO aws_01_staging.selefra_meta_kv"), strings.Index(sql, "ALTER SCHEMA"))
+
+	sql = swapSchemaSql("aws_01", "aws_01_snapshot", fetchTime)
+	require.Contains(t, sql, "DROP SCHEMA IF EXISTS aws_01_snapshot CASCADE;\nALTER SCHEMA aws_01 RENAME TO aws_01_snapshot;\nALTER SCHEMA aws_01_staging RENAME TO aws_01;\nCOMMIT;")
+	require.NotContains(t, sql, "_retired")
+}
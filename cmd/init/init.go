@@ -11,12 +11,10 @@ import (
 	"github.com/selefra/selefra/pkg/httpClient"
 	"github.com/selefra/selefra/pkg/pgstorage"
 	"gopkg.in/yaml.v3"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/selefra/selefra-provider-sdk/grpc/shard"
 	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
 	"github.com/selefra/selefra-utils/pkg/pointer"
@@ -137,7 +135,7 @@ func setSelefraConfig(cmd *cobra.Command) (*config.SelefraConfig, error) {
 func setProviderConfig(cmd *cobra.Command, configYaml *config.RootConfig) error {
 	ctx := cmd.Context()
 
-	prov, err := getProvidersList()
+	prov, err := getProvidersList(configYaml.Selefra)
 	if err != nil {
 		return err
 	}
@@ -152,7 +150,7 @@ func setProviderConfig(cmd *cobra.Command, configYaml *config.RootConfig) error
 	if err != nil {
 		return err
 	}
-	provider := registry.NewProviderRegistry(namespace)
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(configYaml.Selefra)...)
 
 	for _, s := range provs {
 		pr := registry.Provider{
@@ -160,7 +158,7 @@ func setProviderConfig(cmd *cobra.Command, configYaml *config.RootConfig) error
 			Version: "latest",
 			Source:  "",
 		}
-		p, err := provider.Download(ctx, pr, true)
+		p, err := provider.Download(ctx, pr, false)
 		if err != nil {
 			return fmt.Errorf("	Installed %s@%s failed：%s", p.Name, p.Version, err.Error())
 		}
@@ -323,26 +321,19 @@ func setInitGlobalVariable(args []string) error {
 	return nil
 }
 
-func getProvidersList() ([]string, error) {
-	var prov []string
+// getProvidersList return the providers of the registry configured in the selefra block
+func getProvidersList(selefra config.SelefraConfig) ([]string, error) {
 	ui.Infoln("Getting provider list...")
-	req, _ := http.NewRequest("GET", "https://github.com/selefra/registry/file-list/main/provider", nil)
-	client := http.Client{}
-	res, err := client.Do(req)
+	namespace, _, err := utils.Home()
 	if err != nil {
 		ui.Errorf("Error: %s", err.Error())
 		return nil, err
 	}
-	d, err := goquery.NewDocumentFromReader(res.Body)
+	prov, err := registry.NewProviderRegistry(namespace, tools.RegistryOptions(selefra)...).List(context.Background())
 	if err != nil {
 		ui.Errorf("Error: %s", err.Error())
 		return nil, err
 	}
-	d.Find(".js-navigation-open.Link--primary").Each(func(i int, s *goquery.Selection) {
-		if s.Text() != "template" {
-			prov = append(prov, s.Text())
-		}
-	})
 	return prov, nil
 }
 
@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+const (
+	stateHeld    = "held"
+	stateExpired = "expired"
+	stateStale   = "stale"
+)
+
+func newCmdLockList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "list",
+		Short:            "List the locks of the schemas of every provider",
+		Long:             "List the locks of the schemas of every provider with their holders, an expired or stale lock is broken by the next sync, selefra lock release breaks any lock at once",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			rootConfig, err := config.GetConfig()
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+
+			var body [][]string
+			now := time.Now()
+			for _, ps := range tools.ProviderSchemas(rootConfig) {
+				store, err := schemaStorage(ctx, ps.Schema)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				if store == nil {
+					continue
+				}
+				locks, err := pgstorage.ListLocks(ctx, store)
+				store.Close()
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				for _, l := range locks {
+					body = append(body, lockRow(ps.Provider.Name, l, now))
+				}
+			}
+			if len(body) == 0 {
+				ui.Successln("No lock is held")
+				return nil
+			}
+			table.ShowTable([]string{"Provider", "Schema", "State", "Host", "Pid", "Command", "Acquired At", "Expires At"}, body, []string{}, true)
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+func lockRow(provider string, l *pgstorage.LockInfo, now time.Time) []string {
+	state := stateHeld
+	if l.Expired(now) {
+		state = stateExpired
+	}
+	row := []string{provider, l.Id, state, "-", "-", "-", "-", l.ExpiresAt.Local().Format(time.RFC3339)}
+	if l.Holder != nil {
+		if l.Holder.Stale() {
+			row[2] = stateStale
+		}
+		row[3] = l.Holder.Host
+		row[4] = strconv.Itoa(l.Holder.Pid)
+		row[5] = l.Holder.Command
+		row[6] = l.Holder.AcquiredAt.Local().Format(time.RFC3339)
+	}
+	return row
+}
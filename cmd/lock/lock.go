@@ -0,0 +1,49 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewLockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock [command]",
+		Short: "Top-level command to inspect and release the locks of provider schemas and upgrade selefra.lock.yaml",
+		Long:  "Top-level command to inspect and release the locks of provider schemas, a sync holds the lock of the schema of every provider it fetches, and to upgrade the versions of providers and modules locked in selefra.lock.yaml",
+	}
+
+	cmd.AddCommand(newCmdLockList(), newCmdLockRelease(), newCmdLockUpgrade())
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+// schemaStorage return the storage of schema, nil if schema has no key value table to keep locks in
+func schemaStorage(ctx context.Context, schema string) (*postgresql_storage.PostgresqlStorage, error) {
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	defer sto.Close()
+	res, diag := sto.Query(ctx, "SELECT to_regclass($1)::text", schema+".selefra_meta_kv")
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	if matrix := rows.GetMatrix(); len(matrix) == 0 || utils.Strava(matrix[0][0]) == "" {
+		return nil, nil
+	}
+
+	store, diag := pgstorage.PgStorage(ctx, pgstorage.WithSearchPaths(schema))
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	return store, nil
+}
@@ -0,0 +1,91 @@
+package lock
+
+import (
+	"errors"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/ui"
+	"github.com/spf13/cobra"
+)
+
+func newCmdLockRelease() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "release [provider or schema...]",
+		Short:            "Force release the locks of the schemas of providers",
+		Long:             "Force release the locks of the schemas of providers whoever holds them, use it to recover from a sync which crashed on another host, a running sync loses its lock",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			all, _ := cmd.Flags().GetBool("all")
+			if len(args) == 0 && !all {
+				err := errors.New("give the providers or schemas to release, or --all to release the locks of all providers")
+				ui.Errorln(err.Error())
+				return err
+			}
+			rootConfig, err := config.GetConfig()
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+
+			var targets = make(map[string]bool)
+			for _, arg := range args {
+				targets[arg] = true
+			}
+			var matched = make(map[string]bool)
+			var released int
+			for _, ps := range tools.ProviderSchemas(rootConfig) {
+				if !all && !targets[ps.Provider.Name] && !targets[ps.Schema] {
+					continue
+				}
+				matched[ps.Provider.Name] = true
+				matched[ps.Schema] = true
+				store, err := schemaStorage(ctx, ps.Schema)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				if store == nil {
+					continue
+				}
+				locks, err := pgstorage.ListLocks(ctx, store)
+				if err != nil {
+					store.Close()
+					ui.Errorln(err.Error())
+					return err
+				}
+				for _, l := range locks {
+					ok, err := pgstorage.BreakLock(ctx, store, l.Id)
+					if err != nil {
+						store.Close()
+						ui.Errorln(err.Error())
+						return err
+					}
+					if ok {
+						released++
+						ui.Successf("Released the lock of %s held %s\n", l.Id, l)
+					}
+				}
+				store.Close()
+			}
+			for _, arg := range args {
+				if !matched[arg] {
+					err := errors.New("no provider or schema named " + arg)
+					ui.Errorln(err.Error())
+					return err
+				}
+			}
+			if released == 0 {
+				ui.Successln("No lock is held")
+			}
+			return nil
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().Bool("all", false, "release the locks of all providers")
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
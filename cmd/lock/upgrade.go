@@ -0,0 +1,23 @@
+package lock
+
+import (
+	"github.com/selefra/selefra/cmd/provider"
+	"github.com/selefra/selefra/global"
+	"github.com/spf13/cobra"
+)
+
+func newCmdLockUpgrade() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "upgrade [provider or module...]",
+		Short:            "Upgrade the versions locked in selefra.lock.yaml",
+		Long:             "Resolve the versions of the providers and modules declared again and lock them in selefra.lock.yaml, all of them are upgraded if none is given, a module is named such as selefra/aws_compliance",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		SilenceUsage:     true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return provider.UpgradeLock(cmd.Context(), args)
+		},
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+func newCmdProviderInfo() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "info <name>",
+		Short:            "Show the information of a provider in the registry",
+		Long:             "Show the introduction and versions of a provider in the registry, and the tables of the provider if it is installed",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		Args:             cobra.ExactArgs(1),
+		SilenceUsage:     true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return info(cmd.Context(), args[0])
+		},
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+func info(ctx context.Context, name string) error {
+	provider, rootConfig, err := projectRegistry()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	metadata, err := provider.Metadata(ctx, name)
+	if err != nil {
+		err = fmt.Errorf("get provider %s from the registry failed: %s", name, err.Error())
+		ui.Errorln(err.Error())
+		return err
+	}
+	fmt.Printf("  %-16s %s\n", "Name:", name)
+	fmt.Printf("  %-16s %s\n", "Introduction:", metadata.Introduction)
+	fmt.Printf("  %-16s %s\n", "Latest Version:", metadata.LatestVersion)
+	fmt.Printf("  %-16s %s\n", "Latest Update:", metadata.LatestUpdate)
+	fmt.Printf("  %-16s %s\n", "Versions:", strings.Join(metadata.Versions, ", "))
+
+	path, version := installedBinary(rootConfig, name, metadata)
+	if path == "" {
+		ui.Warningf("\n%s is not installed, run selefra provider install %s to list its tables\n", name, name)
+		return nil
+	}
+	tables, err := tools.BinaryTables(ctx, path, name, version)
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	ui.Successf("\n%s@%s has %d tables:\n", name, version, len(tables))
+	var body [][]string
+	for _, t := range tables {
+		body = append(body, []string{t.TableName, utils.Truncate(t.Description, 100)})
+	}
+	table.ShowTable([]string{"Table", "Description"}, body, []string{}, true)
+	return nil
+}
+
+// installedBinary return the path and version of the binary of provider name installed, the one declared by the
+// project in the workspace is preferred to the latest version installed. the path is empty if it is not installed
+func installedBinary(rootConfig *config.RootConfig, name string, metadata registry.ProviderMetadata) (string, string) {
+	var candidates [][2]string
+	if rootConfig != nil {
+		for _, decl := range rootConfig.Selefra.ProviderDecls {
+			if decl.Name != name {
+				continue
+			}
+			if decl.Path != "" {
+				candidates = append(candidates, [2]string{decl.Path, decl.Version})
+			} else if decl.Source != nil {
+				candidates = append(candidates, [2]string{utils.GetPathBySource(*decl.Source, decl.Version), decl.Version})
+			}
+		}
+	}
+	candidates = append(candidates,
+		[2]string{utils.GetPathBySource("selefra/"+name, metadata.LatestVersion), metadata.LatestVersion},
+		[2]string{utils.GetPathBySource("selefra/"+name, "latest"), ""})
+	for _, c := range candidates {
+		if c[0] == "" {
+			continue
+		}
+		if _, err := os.Stat(c[0]); err != nil {
+			continue
+		}
+		version := c[1]
+		if v := registry.InstalledVersion(c[0]); v != "" {
+			version = v
+		}
+		return c[0], version
+	}
+	return "", ""
+}
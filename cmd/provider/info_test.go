@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstalledBinary(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "download", "providers", "aws_v0.0.9", "selefra-provider-aws")
+	require.NoError(t, os.MkdirAll(filepath.Dir(binary), 0755))
+	require.NoError(t, os.WriteFile(binary, []byte("binary"), 0755))
+	metadata := registry.ProviderMetadata{Name: "aws", LatestVersion: "v0.0.10"}
+
+	rootConfig := &config.RootConfig{}
+	rootConfig.Selefra.ProviderDecls = []*config.ProviderDecl{{Name: "aws", Version: "latest", Path: binary}}
+	path, version := installedBinary(rootConfig, "aws", metadata)
+	require.Equal(t, binary, path)
+	require.Equal(t, "v0.0.9", version)
+
+	rootConfig.Selefra.ProviderDecls[0].Path = filepath.Join(filepath.Dir(binary), "missing")
+	path, _ = installedBinary(rootConfig, "aws-not-installed", metadata)
+	require.Equal(t, "", path)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/pgstorage"
 	"github.com/selefra/selefra/pkg/plugin"
 	"github.com/selefra/selefra/pkg/registry"
@@ -50,7 +51,12 @@ func install(ctx context.Context, args []string) error {
 		return nil
 	}
 
-	provider := registry.NewProviderRegistry(namespace)
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(configYaml.Selefra)...)
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
 	for _, s := range args {
 		splitArr := strings.Split(s, "@")
 		var name string
@@ -67,7 +73,7 @@ func install(ctx context.Context, args []string) error {
 			Version: version,
 			Source:  "",
 		}
-		p, err := provider.Download(ctx, pr, true)
+		p, err := provider.Download(ctx, pr, false)
 		continueFlag := false
 		for _, provider := range configYaml.Selefra.ProviderDecls {
 			providerName := *provider.Source
@@ -94,7 +100,11 @@ func install(ctx context.Context, args []string) error {
 		}
 
 		plugProvider := plug.Provider()
-		storageOpt := pgstorage.DefaultPgStorageOpts()
+		storageOpt, err := pgstorage.DefaultPgStorageOpts()
+		if err != nil {
+			ui.Errorf("Synchronization %s@%s's config failed：%s", p.Name, p.Version, err.Error())
+			return nil
+		}
 		opt, err := json.Marshal(storageOpt)
 		initRes, err := plugProvider.Init(ctx, &shard.ProviderInitRequest{
 			Workspace: pointer.ToStringPointer(global.WorkSpace()),
@@ -129,6 +139,11 @@ func install(ctx context.Context, args []string) error {
 			ui.Errorln(err.Error())
 			return nil
 		}
+		decls := configYaml.Selefra.ProviderDecls
+		if err := lockProvider(ctx, provider, decls[len(decls)-1], lock, p); err != nil {
+			ui.Errorln(err.Error())
+			return nil
+		}
 		hasProvider := false
 		for _, Node := range configYaml.Providers.Content {
 			if Node.Kind == yaml.ScalarNode && Node.Value == p.Name {
@@ -145,6 +160,11 @@ func install(ctx context.Context, args []string) error {
 		}
 	}
 
+	if err := lock.Save(); err != nil {
+		ui.Errorln(err.Error())
+		return nil
+	}
+
 	str, err := yaml.Marshal(configYaml)
 	if err != nil {
 		ui.Errorln(err.Error())
@@ -2,8 +2,10 @@ package provider
 
 import (
 	"fmt"
+	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/ui"
 	"github.com/spf13/cobra"
 )
@@ -30,9 +32,14 @@ func list() error {
 		ui.Errorln("Error:" + err.Error())
 		return nil
 	}
-	fmt.Printf("  %-13s %-26s %s\n", "Name", "Source", "Version")
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		ui.Errorln("Error:" + err.Error())
+		return nil
+	}
+	fmt.Printf("  %-13s %-26s %-20s %s\n", "Name", "Source", "Version", "Resolved")
 	for _, provider := range configYaml.Selefra.ProviderDecls {
-		fmt.Printf("  %-13s %-26s %s\n", provider.Name, *provider.Source, provider.Version)
+		fmt.Printf("  %-13s %-26s %-20s %s\n", provider.Name, *provider.Source, provider.Version, tools.ResolvedVersion(provider, lock))
 	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+)
+
+// lockedProvider return the provider to download for decl, it is the version locked if decl is locked with the
+// version it declares. the checksum locked for the current platform must match the one in the registry
+func lockedProvider(ctx context.Context, provider registry.RegisterProvider, decl *config.ProviderDecl, lock *lockfile.LockFile) (registry.Provider, error) {
+	prov := registry.Provider{
+		Name:    decl.Name,
+		Version: decl.Version,
+		Source:  "",
+		Path:    decl.Path,
+	}
+	locked := lock.Provider(decl.Name)
+	if decl.Path != "" || locked == nil || locked.Constraint != decl.Version {
+		return prov, nil
+	}
+	prov.Version = locked.Version
+	checksums, err := provider.Checksums(ctx, prov)
+	if err != nil {
+		return prov, err
+	}
+	platform := registry.CurrentPlatform().String()
+	if want := locked.Checksums[platform]; want != "" && checksums[platform] != want {
+		return prov, fmt.Errorf("the checksum of %s for %s in the registry does not match %s, run selefra lock upgrade if the change is expected",
+			prov.String(), platform, lockfile.FileName)
+	}
+	return prov, nil
+}
+
+// lockProvider lock the provider downloaded for decl, a provider locked with the same version is kept
+func lockProvider(ctx context.Context, provider registry.RegisterProvider, decl *config.ProviderDecl, lock *lockfile.LockFile, pp registry.ProviderBinary) error {
+	// a provider binary in the local path is not downloaded from the registry
+	if decl.Path != "" {
+		return nil
+	}
+	if locked := lock.Provider(decl.Name); locked != nil && locked.Constraint == decl.Version && locked.Version == pp.Version {
+		return nil
+	}
+	checksums, err := provider.Checksums(ctx, pp.Provider)
+	if err != nil {
+		return err
+	}
+	var source string
+	if decl.Source != nil {
+		source = *decl.Source
+	}
+	lock.SetProvider(&lockfile.Provider{
+		Name:       decl.Name,
+		Source:     source,
+		Constraint: decl.Version,
+		Version:    pp.Version,
+		Checksums:  checksums,
+	})
+	return nil
+}
+
+// UpgradeLock resolve the versions of the providers and modules named again and lock them, all the providers
+// and modules are upgraded if no name is given
+func UpgradeLock(ctx context.Context, names []string) error {
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	namespace, _, err := utils.Home()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	var upgraded = make(map[string]bool)
+	for _, name := range names {
+		upgraded[name] = true
+	}
+	lock.Unlock(names...)
+
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(rootConfig.Selefra)...)
+	for _, decl := range rootConfig.Selefra.ProviderDecls {
+		if len(names) > 0 && !upgraded[decl.Name] {
+			continue
+		}
+		pp, err := provider.Download(ctx, registry.Provider{Name: decl.Name, Version: decl.Version, Path: decl.Path}, false)
+		if err != nil {
+			ui.Errorf("Upgrade %s@%s failed: %s\n", decl.Name, decl.Version, err.Error())
+			return err
+		}
+		if decl.Path == "" {
+			if err := tools.AppendProviderDecl(pp, nil, decl.Version); err != nil {
+				return err
+			}
+		}
+		if err := lockProvider(ctx, provider, decl, lock, pp); err != nil {
+			ui.Errorf("Upgrade %s@%s failed: %s\n", decl.Name, decl.Version, err.Error())
+			return err
+		}
+		ui.Successf("Locked %s@%s\n", decl.Name, pp.Version)
+	}
+	if err := lock.Save(); err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+
+	// the modules unlocked are locked again once resolved
+	if _, err := config.GetModules(); err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/pkg/lockfile"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockedProvider(t *testing.T) {
+	src := t.TempDir()
+	versionDir := filepath.Join(src, "provider", "aws", "v0.0.9")
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+	supplement, err := yaml.Marshal(registry.ProviderSupplement{
+		PackageName: "selefra-provider-aws",
+		Checksums:   registry.Checksums{LinuxAmd64: "a", LinuxArm64: "a", DarwinAmd64: "a", DarwinArm64: "a", WindowsAmd64: "a", WindowsArm64: "a"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "supplement.yaml"), supplement, 0644))
+
+	ctx := context.Background()
+	provider := registry.NewProviderRegistry(t.TempDir(), registry.WithURL(src))
+	lock, err := lockfile.Load(t.TempDir())
+	require.NoError(t, err)
+	decl := &config.ProviderDecl{Name: "aws", Version: "latest"}
+
+	// a provider not locked is resolved by the registry
+	prov, err := lockedProvider(ctx, provider, decl, lock)
+	require.NoError(t, err)
+	require.Equal(t, "latest", prov.Version)
+
+	require.NoError(t, lockProvider(ctx, provider, decl, lock, registry.ProviderBinary{Provider: registry.Provider{Name: "aws", Version: "v0.0.9"}}))
+	locked := lock.Provider("aws")
+	require.Equal(t, "latest", locked.Constraint)
+	require.Equal(t, "v0.0.9", locked.Version)
+	require.Equal(t, "a", locked.Checksums[registry.CurrentPlatform().String()])
+
+	prov, err = lockedProvider(ctx, provider, decl, lock)
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.9", prov.Version)
+
+	// the archive in the registry is replaced
+	locked.Checksums[registry.CurrentPlatform().String()] = "b"
+	_, err = lockedProvider(ctx, provider, decl, lock)
+	require.Error(t, err)
+
+	// the declared version is changed
+	prov, err = lockedProvider(ctx, provider, &config.ProviderDecl{Name: "aws", Version: "~> 0.1"}, lock)
+	require.NoError(t, err)
+	require.Equal(t, "~> 0.1", prov.Version)
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/spf13/cobra"
+)
+
+func newCmdProviderMirror() *cobra.Command {
+	var platforms []string
+	cmd := &cobra.Command{
+		Use:   "mirror <dir>",
+		Short: "Download the providers of the project into a directory which can be used as a registry",
+		Long: `Download the providers declared in the project for the platforms into a directory,
+the directory is laid out like the registry, set selefra.registry or SELEFRA_REGISTRY to it on an air-gapped host`,
+		PersistentPreRun: global.DefaultWrappedInit(),
+		Args:             cobra.ExactArgs(1),
+		SilenceUsage:     true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return mirror(cmd.Context(), args[0], platforms)
+		},
+	}
+	cmd.PersistentFlags().StringSliceVar(&platforms, "platform", []string{registry.CurrentPlatform().String()}, "the platforms to download providers for, such as linux_amd64,darwin_arm64")
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+func mirror(ctx context.Context, dir string, platformNames []string) error {
+	var platforms []registry.Platform
+	for _, name := range platformNames {
+		platform, err := registry.ParsePlatform(name)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		platforms = append(platforms, platform)
+	}
+
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	namespace, _, err := utils.Home()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(rootConfig.Selefra)...)
+	for _, decl := range rootConfig.Selefra.ProviderDecls {
+		p, err := provider.Mirror(ctx, registry.Provider{Name: decl.Name, Version: decl.Version}, dir, platforms)
+		if err != nil {
+			ui.Errorf("Mirror %s@%s failed: %s\n", decl.Name, decl.Version, err.Error())
+			return err
+		}
+		ui.Successln(fmt.Sprintf("Mirrored %s for %v", p.String(), platformNames))
+	}
+	return nil
+}
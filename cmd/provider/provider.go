@@ -11,7 +11,7 @@ func NewProviderCmd() *cobra.Command {
 		Long:  "Top-level command to interact with providers",
 	}
 
-	cmd.AddCommand(newCmdProviderUpdate(), newCmdProviderRemove(), newCmdProviderRemove(), newCmdProviderList(), newCmdProviderInstall())
+	cmd.AddCommand(newCmdProviderUpdate(), newCmdProviderRemove(), newCmdProviderRemove(), newCmdProviderList(), newCmdProviderInstall(), newCmdProviderMirror(), newCmdProviderSearch(), newCmdProviderInfo())
 
 	cmd.SetHelpFunc(cmd.HelpFunc())
 	return cmd
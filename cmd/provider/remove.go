@@ -3,6 +3,7 @@ package provider
 import (
 	"encoding/json"
 	"errors"
+	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
 	"github.com/selefra/selefra/pkg/registry"
@@ -41,7 +42,7 @@ func Remove(names []string) error {
 	if err != nil {
 		return err
 	}
-	provider := registry.NewProviderRegistry(namespace)
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(cof.Selefra)...)
 
 	for _, p := range cof.Selefra.ProviderDecls {
 		name := *p.Source
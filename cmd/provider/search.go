@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/registry"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"github.com/spf13/cobra"
+)
+
+func newCmdProviderSearch() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "search [term]",
+		Short:            "Search the providers in the registry",
+		Long:             "Search the providers in the registry whose name or introduction contains term, all the providers are listed if no term is given",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		Args:             cobra.MaximumNArgs(1),
+		SilenceUsage:     true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var term string
+			if len(args) > 0 {
+				term = args[0]
+			}
+			return search(cmd.Context(), term)
+		},
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+func search(ctx context.Context, term string) error {
+	provider, _, err := projectRegistry()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	providers, err := provider.Search(ctx, term)
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	if len(providers) == 0 {
+		ui.Warningf("No provider matches %q\n", term)
+		return nil
+	}
+	var body [][]string
+	for _, metadata := range providers {
+		body = append(body, []string{metadata.Name, metadata.LatestVersion, metadata.LatestUpdate, metadata.Introduction})
+	}
+	table.ShowTable([]string{"Name", "Latest Version", "Latest Update", "Introduction"}, body, []string{}, true)
+	return nil
+}
+
+// projectRegistry return the registry configured by the project in the workspace and its config,
+// it is the default registry and a nil config outside a project
+func projectRegistry() (registry.RegisterProvider, *config.RootConfig, error) {
+	namespace, _, err := utils.Home()
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.IsSelefra() != nil {
+		return registry.NewProviderRegistry(namespace), nil, nil
+	}
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry.NewProviderRegistry(namespace, tools.RegistryOptions(rootConfig.Selefra)...), rootConfig, nil
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/selefra/selefra-provider-sdk/provider/schema"
 	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
@@ -13,46 +14,74 @@ import (
 	"github.com/selefra/selefra/global"
 	"github.com/selefra/selefra/pkg/grpcClient"
 	"github.com/selefra/selefra/pkg/httpClient"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/logger"
 	"github.com/selefra/selefra/pkg/pgstorage"
 	"github.com/selefra/selefra/pkg/registry"
 	"github.com/selefra/selefra/pkg/utils"
 	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/progress"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
+// ErrSyncFailed is returned by Sync when some providers failed to sync, the providers synced successfully are still usable
+var ErrSyncFailed = errors.New("some providers failed to sync")
+
 type lockStruct struct {
 	SchemaKey string
 	Uuid      string
 	Storage   *postgresql_storage.PostgresqlStorage
 }
 
+// Release release the lock of the schema
+func (l lockStruct) Release(ctx context.Context) error {
+	return pgstorage.ReleaseLock(ctx, l.Storage, l.SchemaKey, l.Uuid)
+}
+
 // effectiveDecls check provider decls and download provider binary file, return the effective providers
-func effectiveDecls(ctx context.Context, decls []*config.ProviderDecl) (effects []*config.ProviderDecl, errlogs []string) {
+func effectiveDecls(ctx context.Context, decls []*config.ProviderDecl, opts ...registry.Option) (effects []*config.ProviderDecl, errlogs []string) {
 	namespace, _, err := utils.Home()
 	if err != nil {
 		errlogs = append(errlogs, err.Error())
 		return
 	}
-	provider := registry.NewProviderRegistry(namespace)
+	provider := registry.NewProviderRegistry(namespace, opts...)
 	ui.Successf("Selefra has been successfully installed providers!\n\n")
 	ui.Successf("Checking Selefra provider updates......\n")
 
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		errlogs = append(errlogs, err.Error())
+		return
+	}
 	for _, decl := range decls {
 		configVersion := decl.Version
-		prov := registry.Provider{
-			Name:    decl.Name,
-			Version: decl.Version,
-			Source:  "",
-			Path:    decl.Path,
+		// the version locked in the workspace is used
+		prov, err := lockedProvider(ctx, provider, decl, lock)
+		if err != nil {
+			ui.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
+			errlogs = append(errlogs, err.Error())
+			continue
 		}
-		pp, err := provider.Download(ctx, prov, true)
+		// latest stays in the major version installed, it is moved to a new major version by provider update
+		if (prov.Version == "" || prov.Version == "latest") && decl.Source != nil {
+			if constraint := registry.LatestConstraint(registry.InstalledVersion(utils.GetPathBySource(*decl.Source, "latest"))); constraint != "" {
+				prov.Version = constraint
+			}
+		}
+		pp, err := provider.Download(ctx, prov, false)
 		if err != nil {
 			ui.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
 			errlogs = append(errlogs, err.Error())
 			continue
 		} else {
+			if err := lockProvider(ctx, provider, decl, lock, pp); err != nil {
+				ui.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
+				errlogs = append(errlogs, err.Error())
+				continue
+			}
 			decl.Path = pp.Filepath
 			decl.Version = pp.Version
 			err = tools.AppendProviderDecl(pp, nil, configVersion)
@@ -65,11 +94,17 @@ func effectiveDecls(ctx context.Context, decls []*config.ProviderDecl) (effects
 			ui.Successf("	%s@%s all ready updated!\n", decl.Name, decl.Version)
 		}
 	}
+	if err := lock.Save(); err != nil {
+		ui.Errorln(err.Error())
+	}
 
-	return effects, nil
+	return effects, errlogs
 }
 
-func Sync(ctx context.Context) (lockSlice []lockStruct, err error) {
+// Sync update the providers and fetch the resources of every provider instance whose cache expired,
+// at most parallelism instances are synced concurrently, every instance holds the lock of its schema,
+// an instance fails if the lock of its schema is not acquired within lockTimeout, zero waits without limit
+func Sync(ctx context.Context, parallelism int, lockTimeout time.Duration) (lockSlice []lockStruct, err error) {
 	// load and check config
 	ui.Infof("Initializing provider plugins...\n\n")
 	rootConfig, err := config.GetConfig()
@@ -86,75 +121,108 @@ func Sync(ctx context.Context) (lockSlice []lockStruct, err error) {
 		ui.Errorln(err.Error())
 	}
 
-	var errored bool
+	return syncProviders(ctx, rootConfig, parallelism, lockTimeout)
+}
+
+// syncProviders update the providers of rootConfig and sync their instances, ErrSyncFailed is returned
+// if a provider failed to update or an instance failed to sync
+func syncProviders(ctx context.Context, rootConfig *config.RootConfig, parallelism int, lockTimeout time.Duration) (lockSlice []lockStruct, err error) {
+	providerDecls, errLogs := effectiveDecls(ctx, rootConfig.Selefra.ProviderDecls, tools.RegistryOptions(rootConfig.Selefra)...)
 
-	providerDecls, errLogs := effectiveDecls(ctx, rootConfig.Selefra.ProviderDecls)
+	errored := len(errLogs) > 0
 
 	ui.Successf("Selefra has been finished update providers!\n")
 
 	global.SetStage("pull")
+	type instance struct {
+		decl *config.ProviderDecl
+		prvd *config.Provider
+	}
+	var instances []instance
 	for _, decl := range providerDecls {
-		prvds := tools.ProvidersByID(rootConfig, decl.Name)
-		for _, prvd := range prvds {
-
-			// build a postgresql storage
-			schemaKey := config.GetSchemaKey(decl, *prvd)
-			store, err := pgstorage.PgStorageWithMeta(ctx, &schema.ClientMeta{
-				ClientLogger: logger.NewSchemaLoggeer(),
-			}, pgstorage.WithSearchPath(config.GetSchemaKey(decl, *prvd)))
-			if err != nil {
-				errored = true
-				ui.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
-				errLogs = append(errLogs, fmt.Sprintf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error()))
-				continue
-			}
-
-			// try lock
-			// TODO: check unlock
-			uuid := id_util.RandomId()
-			for {
-				err = store.Lock(ctx, schemaKey, uuid)
-				if err == nil {
-					lockSlice = append(lockSlice, lockStruct{
-						SchemaKey: schemaKey,
-						Uuid:      uuid,
-						Storage:   store,
-					})
-					break
-				}
-				time.Sleep(5 * time.Second)
-			}
-
-			// check if cache expired
-			expired, _ := tools.CacheExpired(ctx, store, prvd.Cache)
-			if !expired {
-				ui.Successf("%s %s@%s pull infrastructure data:\n", prvd.Name, decl.Name, decl.Version)
-				ui.Print(fmt.Sprintf("Pulling %s@%s Please wait for resource information ...", decl.Name, decl.Version), false)
-				ui.Successf("	%s@%s all ready use cache!\n", decl.Name, decl.Version)
-				continue
-			}
-
-			// if expired, fetch new data
-			err = fetch.Fetch(ctx, decl, prvd)
-			if err != nil {
-				ui.Errorf("%s %s Synchronization failed：%s", decl.Name, decl.Version, err.Error())
-				errored = true
-				continue
-			}
-
-			// set fetch time
-			if err := pgstorage.SetStorageValue(ctx, store, config.GetCacheKey(), time.Now().Format(time.RFC3339)); err != nil {
-				ui.Warningf("%s %s set cache time failed：%s", decl.Name, decl.Version, err.Error())
-				errored = true
-				continue
-			}
+		for _, prvd := range tools.ProvidersByID(rootConfig, decl.Name) {
+			instances = append(instances, instance{decl: decl, prvd: prvd})
 		}
 	}
+
+	progbar := progress.CreateProgress()
+	var mu sync.Mutex
+	utils.Parallel(len(instances), parallelism, func(i int) {
+		lock, err := syncProvider(ctx, instances[i].decl, instances[i].prvd, progbar, lockTimeout)
+		mu.Lock()
+		defer mu.Unlock()
+		if lock != nil {
+			lockSlice = append(lockSlice, *lock)
+		}
+		if err != nil {
+			errored = true
+			errLogs = append(errLogs, err.Error())
+		}
+	})
 	if errored {
 		ui.Errorf(`
 This may be exception, view detailed exception in %s .
 `, filepath.Join(global.WorkSpace(), "logs"))
+		return lockSlice, ErrSyncFailed
 	}
 
 	return lockSlice, nil
 }
+
+// syncProvider lock the schema of a provider instance and fetch its resources if the cache expired,
+// the lock is returned once it is held even if the fetch failed
+func syncProvider(ctx context.Context, decl *config.ProviderDecl, prvd *config.Provider, progbar *progress.Progress, lockTimeout time.Duration) (*lockStruct, error) {
+	// build a postgresql storage
+	schemaKey := config.GetSchemaKey(decl, *prvd)
+	store, err := pgstorage.PgStorageWithMeta(ctx, &schema.ClientMeta{
+		ClientLogger: logger.NewSchemaLoggeer(),
+	}, pgstorage.WithSearchPath(schemaKey))
+	if err != nil {
+		ui.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
+		return nil, fmt.Errorf("%s@%s failed updated：%s", decl.Name, decl.Version, err.Error())
+	}
+
+	// wait for the lock, it is broken if its holder crashed
+	uuid := id_util.RandomId()
+	if err := pgstorage.AcquireLock(ctx, store, schemaKey, pgstorage.NewLockHolder(uuid), lockTimeout); err != nil {
+		ui.Errorf("%s %s@%s failed to lock %s：%s\n", prvd.Name, decl.Name, decl.Version, schemaKey, err.Error())
+		return nil, err
+	}
+	lock := &lockStruct{
+		SchemaKey: schemaKey,
+		Uuid:      uuid,
+		Storage:   store,
+	}
+
+	// check which tables expired, the tables within their cache time keep the data fetched last time
+	expiredTables, all, _ := tools.ExpiredTables(ctx, store, decl, prvd)
+	if !all && len(expiredTables) == 0 {
+		ui.Successf("%s %s@%s pull infrastructure data:\n", prvd.Name, decl.Name, decl.Version)
+		ui.Print(fmt.Sprintf("Pulling %s@%s Please wait for resource information ...", decl.Name, decl.Version), false)
+		ui.Successf("	%s@%s all ready use cache!\n", decl.Name, decl.Version)
+		return lock, nil
+	}
+
+	// if expired, fetch new data, a pull interrupted within the cache time is resumed
+	cacheDuration, _ := tools.CacheDuration(prvd.Cache)
+	opts := fetch.FetchOptions{
+		Progress:     progbar,
+		Resume:       cacheDuration > 0,
+		ResumeWithin: cacheDuration,
+	}
+	if !all {
+		opts.Tables = expiredTables
+	}
+	err = fetch.FetchWithOptions(ctx, decl, prvd, opts)
+	if err != nil {
+		ui.Errorf("%s %s Synchronization failed：%s", decl.Name, decl.Version, err.Error())
+		return lock, err
+	}
+
+	// set fetch time
+	if err := pgstorage.SetStorageValue(ctx, store, config.GetCacheKey(), time.Now().Format(time.RFC3339)); err != nil {
+		ui.Warningf("%s %s set cache time failed：%s", decl.Name, decl.Version, err.Error())
+		return lock, err
+	}
+	return lock, nil
+}
@@ -2,9 +2,14 @@ package provider
 
 import (
 	"context"
+	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/registry"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,10 +21,40 @@ func Test_effectiveDecls(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	decls, _ := effectiveDecls(ctx, rootConfig.Selefra.ProviderDecls)
+	decls, _ := effectiveDecls(ctx, rootConfig.Selefra.ProviderDecls, tools.RegistryOptions(rootConfig.Selefra)...)
 
 	require.Equal(t, 1, len(decls))
 
 	require.Equal(t, "aws", decls[0].Name)
 	require.Equal(t, "v0.0.9", decls[0].Version)
 }
+
+func TestSyncProvidersDownloadFailed(t *testing.T) {
+	src := t.TempDir()
+	versionDir := filepath.Join(src, "provider", "synctest", "v0.0.2")
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+	metadata, err := yaml.Marshal(registry.ProviderMetadata{Name: "synctest", LatestVersion: "v0.0.2", Versions: []string{"v0.0.2"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(src, "provider", "synctest", "metadata.yaml"), metadata, 0644))
+	// the checksum of the archive does not match the registry
+	bad := registry.Checksums{LinuxArm64: "bad", LinuxAmd64: "bad", WindowsArm64: "bad", WindowsAmd64: "bad", DarwinArm64: "bad", DarwinAmd64: "bad"}
+	supplement, err := yaml.Marshal(registry.ProviderSupplement{PackageName: "selefra-provider-synctest", Checksums: bad})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "supplement.yaml"), supplement, 0644))
+	archive := "selefra-provider-synctest_0.0.2_" + registry.CurrentPlatform().String() + ".tar.gz"
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, archive), []byte("archive"), 0644))
+
+	source := "selefra/synctest"
+	rootConfig := &config.RootConfig{}
+	rootConfig.Selefra.Registry = src
+	rootConfig.Selefra.ProviderDecls = []*config.ProviderDecl{{Name: "synctest", Source: &source, Version: "v0.0.2"}}
+
+	decls, errLogs := effectiveDecls(context.Background(), rootConfig.Selefra.ProviderDecls, tools.RegistryOptions(rootConfig.Selefra)...)
+	require.Empty(t, decls)
+	require.Len(t, errLogs, 1)
+	require.Contains(t, errLogs[0], registry.ErrVerifyFailed.Error())
+
+	locks, err := syncProviders(context.Background(), rootConfig, 1, 0)
+	require.ErrorIs(t, err, ErrSyncFailed)
+	require.Empty(t, locks)
+}
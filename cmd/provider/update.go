@@ -6,6 +6,7 @@ import (
 	"github.com/selefra/selefra/cmd/tools"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/registry"
 	"github.com/selefra/selefra/pkg/utils"
 	"github.com/selefra/selefra/ui"
@@ -45,7 +46,12 @@ func update(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
-	provider := registry.NewProviderRegistry(namespace)
+	provider := registry.NewProviderRegistry(namespace, tools.RegistryOptions(rootConfig.Selefra)...)
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
 	for _, decl := range rootConfig.Selefra.ProviderDecls {
 		prov := registry.ProviderBinary{
 			Provider: registry.Provider{
@@ -55,6 +61,9 @@ func update(ctx context.Context, args []string) error {
 			},
 			Filepath: decl.Path,
 		}
+		if prov.Filepath == "" && decl.Source != nil {
+			prov.Filepath = utils.GetPathBySource(*decl.Source, decl.Version)
+		}
 		if len(args) != 0 && !argsMap[decl.Name] {
 			break
 		}
@@ -63,6 +72,17 @@ func update(ctx context.Context, args []string) error {
 		if err != nil {
 			return err
 		}
+		if err := tools.AppendProviderDecl(pp, nil, decl.Version); err != nil {
+			return err
+		}
+		if err := lockProvider(ctx, provider, decl, lock, pp); err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
+		if err := lock.Save(); err != nil {
+			ui.Errorln(err.Error())
+			return err
+		}
 		decl.Path = pp.Filepath
 		decl.Version = pp.Version
 
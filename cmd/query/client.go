@@ -16,8 +16,8 @@ type QueryClient struct {
 	Columns []prompt.Suggest
 }
 
-func NewQueryClient(ctx context.Context) (*QueryClient, error) {
-	sto, diag := pgstorage.Storage(ctx)
+func NewQueryClient(ctx context.Context, opts ...pgstorage.Option) (*QueryClient, error) {
+	sto, diag := pgstorage.Storage(ctx, opts...)
 	if diag != nil {
 		err := ui.PrintDiagnostic(diag.GetDiagnosticSlice())
 		if err != nil {
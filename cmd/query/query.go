@@ -1,8 +1,14 @@
 package query
 
 import (
+	"context"
+	"errors"
 	"github.com/c-bata/go-prompt"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
 	"github.com/selefra/selefra/pkg/utils"
 	"github.com/selefra/selefra/ui"
 	"github.com/selefra/selefra/ui/table"
@@ -15,13 +21,22 @@ func NewQueryCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:              "query",
 		Short:            "Query infrastructure data from pgstorage",
-		Long:             "Query infrastructure data from pgstorage",
+		Long:             "Query infrastructure data from pgstorage, with --snapshot the tables of providers are read from the snapshot",
 		PersistentPreRun: global.DefaultWrappedInit(),
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := cmd.Context()
+			var opts []pgstorage.Option
+			if target, _ := cmd.Flags().GetString("snapshot"); target != "" {
+				schemas, err := snapshotSchemas(ctx, target)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return
+				}
+				opts = append(opts, pgstorage.WithSearchPaths(append(schemas, "public")...))
+			}
 			ui.Warningln("Please select table.")
 
-			queryClient, _ := NewQueryClient(ctx)
+			queryClient, _ := NewQueryClient(ctx, opts...)
 			p := prompt.New(func(in string) {
 				strArr := strings.Split(in, "/")
 				s := strArr[0]
@@ -68,5 +83,29 @@ func NewQueryCmd() *cobra.Command {
 			p.Run()
 		},
 	}
+	cmd.Flags().String("snapshot", "", "query the snapshot of every provider, a snapshot id or a time which selects the data fetched last before it")
 	return cmd
 }
+
+// snapshotSchemas return the schemas of the snapshots selected by target of all configured providers
+func snapshotSchemas(ctx context.Context, target string) ([]string, error) {
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	sto, diag := pgstorage.Storage(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	defer sto.Close()
+
+	var schemas []string
+	for _, ps := range tools.ProviderSchemas(rootConfig) {
+		schema, err := snapshot.Resolve(ctx, sto, ps.Schema, target)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
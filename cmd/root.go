@@ -3,12 +3,16 @@ package cmd
 import (
 	"fmt"
 	"github.com/selefra/selefra/cmd/apply"
+	"github.com/selefra/selefra/cmd/diff"
 	"github.com/selefra/selefra/cmd/fetch"
 	initCmd "github.com/selefra/selefra/cmd/init"
+	"github.com/selefra/selefra/cmd/lock"
 	"github.com/selefra/selefra/cmd/login"
 	"github.com/selefra/selefra/cmd/logout"
 	"github.com/selefra/selefra/cmd/provider"
 	"github.com/selefra/selefra/cmd/query"
+	"github.com/selefra/selefra/cmd/rule"
+	"github.com/selefra/selefra/cmd/snapshot"
 	"github.com/selefra/selefra/cmd/test"
 	"github.com/selefra/selefra/cmd/version"
 	"github.com/selefra/selefra/global"
@@ -46,7 +50,7 @@ func Execute() {
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Printf("Error occurred in Execute: %+v", err)
-		os.Exit(1)
+		os.Exit(global.ExitCode(err))
 	}
 }
 
@@ -62,9 +66,13 @@ func init() {
 	}
 
 	group["other"] = []*cobra.Command{
+		diff.NewDiffCmd(),
 		fetch.NewFetchCmd(),
+		lock.NewLockCmd(),
 		provider.NewProviderCmd(),
 		query.NewQueryCmd(),
+		rule.NewRuleCmd(),
+		snapshot.NewSnapshotCmd(),
 		version.NewVersionCmd(),
 	}
 
@@ -0,0 +1,161 @@
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-provider-sdk/storage"
+	"github.com/selefra/selefra/pkg/utils"
+	"sort"
+	"strings"
+)
+
+// loadFixtures create the tables of fixtures by the schema of the provider tables, which are keyed by lower case
+// table name, and insert the fixture rows into them. the constraints are not created, so a fixture only declares
+// the columns used by the rule rather than the parent rows and the columns which are not null
+func loadFixtures(ctx context.Context, sto storage.Storage, tables map[string]*schema.Table, fixtures map[string][]map[string]interface{}) error {
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fixtureTables []*schema.Table
+	for _, name := range names {
+		table, ok := tables[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("fixture table %s is not a table of the installed providers", name)
+		}
+		fixtureTables = append(fixtureTables, fixtureTable(table))
+	}
+	if diag := sto.TablesCreate(ctx, fixtureTables); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+
+	for i, name := range names {
+		table := fixtureTables[i]
+		rows := fixtures[name]
+		columns, err := fixtureColumns(table, rows)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("fixture %s has no column", name)
+		}
+
+		var quoted, placeholders []string
+		for i, column := range columns {
+			quoted = append(quoted, quoteIdentifier(column.ColumnName))
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		}
+		insertSql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(table.TableName), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+		for _, row := range rows {
+			var args []any
+			for _, column := range columns {
+				value, err := fixtureValue(column, fixtureField(row, column.ColumnName))
+				if err != nil {
+					return fmt.Errorf("fixture %s column %s: %s", name, column.ColumnName, err.Error())
+				}
+				args = append(args, value)
+			}
+			if diag := sto.Exec(ctx, insertSql, args...); diag != nil && diag.HasError() {
+				return errors.New(diag.ToString())
+			}
+		}
+	}
+	return nil
+}
+
+// fixtureTable return a copy of table with only the names and types of columns, the sub tables are not included
+func fixtureTable(table *schema.Table) *schema.Table {
+	var columns []*schema.Column
+	for _, column := range table.Columns {
+		columns = append(columns, &schema.Column{ColumnName: column.ColumnName, Type: column.Type})
+	}
+	return &schema.Table{TableName: table.TableName, Columns: columns}
+}
+
+// fixtureColumns return the columns of table declared by rows sorted by name, the keys of rows are matched
+// case-insensitively and a key which is not a column of table is an error
+func fixtureColumns(table *schema.Table, rows []map[string]interface{}) ([]*schema.Column, error) {
+	var tableColumns = make(map[string]*schema.Column)
+	for _, column := range table.Columns {
+		tableColumns[strings.ToLower(column.ColumnName)] = column
+	}
+	var seen = make(map[string]bool)
+	var columns []*schema.Column
+	for _, row := range rows {
+		for key := range row {
+			column, ok := tableColumns[strings.ToLower(key)]
+			if !ok {
+				return nil, fmt.Errorf("column %s does not exist in table %s", key, table.TableName)
+			}
+			if !seen[column.ColumnName] {
+				seen[column.ColumnName] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].ColumnName < columns[j].ColumnName
+	})
+	return columns, nil
+}
+
+// fixtureField return the value of column in row, the keys of row are matched case-insensitively
+func fixtureField(row map[string]interface{}, column string) interface{} {
+	if value, ok := row[column]; ok {
+		return value
+	}
+	for key, value := range row {
+		if strings.EqualFold(key, column) {
+			return value
+		}
+	}
+	return nil
+}
+
+// fixtureValue convert a value decoded from yaml to the argument of sql by the type of column, json values other
+// than strings are passed as json and lists of array columns are passed as array literals
+func fixtureValue(column *schema.Column, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch column.Type {
+	case schema.ColumnTypeJSON:
+		if _, ok := value.(string); ok {
+			return value, nil
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case schema.ColumnTypeIntArray, schema.ColumnTypeStringArray, schema.ColumnTypeIpArray, schema.ColumnTypeCIDRArray, schema.ColumnTypeMacAddrArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, errors.New("a list is expected")
+		}
+		var elements []string
+		for _, item := range items {
+			if item == nil {
+				elements = append(elements, "NULL")
+				continue
+			}
+			element := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(utils.Strava(item))
+			elements = append(elements, `"`+element+`"`)
+		}
+		return "{" + strings.Join(elements, ",") + "}", nil
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil, fmt.Errorf("a value of %s is expected", column.Type.String())
+	}
+	return value, nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
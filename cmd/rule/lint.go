@@ -0,0 +1,29 @@
+package rule
+
+import (
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/global"
+	"github.com/spf13/cobra"
+)
+
+func newCmdRuleLint() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "lint [rule...]",
+		Short:            "Check the queries and templates of rules",
+		Long:             "Parse the query of rules, check the referenced tables and columns against the fetched provider tables, and check the columns referenced by output and label templates, all rules are checked if no rule name or id is given",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			noTables, _ := cmd.PersistentFlags().GetBool("no-tables")
+			var listTables tools.TableLister = tools.FetchedTables
+			if noTables {
+				listTables = nil
+			}
+			return tools.LintRules(cmd.Context(), args, listTables)
+		},
+		SilenceUsage: true,
+	}
+	cmd.PersistentFlags().Bool("no-tables", false, "skip the table and column checks, which need the database of the fetched provider tables")
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
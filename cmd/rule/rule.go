@@ -0,0 +1,18 @@
+package rule
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewRuleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rule [command]",
+		Short: "Top-level command to test and lint rules",
+		Long:  "Top-level command to test and lint rules",
+	}
+
+	cmd.AddCommand(newCmdRuleTest(), newCmdRuleLint())
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
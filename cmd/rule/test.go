@@ -0,0 +1,197 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-utils/pkg/id_util"
+	"github.com/selefra/selefra/cmd/apply"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func newCmdRuleTest() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "test [rule...]",
+		Short:            "Run the tests of rules on fixture rows",
+		Long:             "Run the tests declared in the tests block of rules or in the *_test.yaml beside rule files, all rules are tested if no rule name or id is given",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Test(cmd.Context(), args)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
+
+// ruleTestCase is a test of a rule
+type ruleTestCase struct {
+	rule config.Rule
+	test config.RuleTest
+}
+
+// Test run the tests of rules whose name or id in names, all rules are tested when names is empty
+func Test(ctx context.Context, names []string) error {
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	rules, err := tools.LoadRules()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	cases, err := ruleTestCases(tools.FilterRules(rules, names))
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	if len(cases) == 0 {
+		ui.Warningln("No rule test found")
+		return nil
+	}
+
+	// the fixture tables are created by the schema of the installed providers
+	providerTables, err := tools.InstalledProviderTables(ctx, rootConfig)
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	var tables = make(map[string]*schema.Table)
+	for _, table := range providerTables {
+		tables[strings.ToLower(table.TableName)] = table
+	}
+
+	variablesMap := tools.VariablesMap(rootConfig)
+	var failed int
+	for _, c := range cases {
+		if err := runRuleTest(ctx, c.rule, c.test, tables, variablesMap); err != nil {
+			failed++
+			ui.Errorf("FAIL	%s - %s: %s\n", c.rule.Name, c.test.Name, err.Error())
+			continue
+		}
+		ui.Successf("PASS	%s - %s\n", c.rule.Name, c.test.Name)
+	}
+	ui.Successf("\n%d passed, %d failed\n", len(cases)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d rule tests failed", failed)
+	}
+	return nil
+}
+
+// ruleTestCases collect the tests in the tests block of rules and in the *_test.yaml beside rule files
+func ruleTestCases(rules []config.Rule) ([]ruleTestCase, error) {
+	var cases []ruleTestCase
+	var fileTests = make(map[string][]config.RuleTest)
+	for _, rule := range rules {
+		for _, test := range rule.Tests {
+			cases = append(cases, ruleTestCase{rule: rule, test: test})
+		}
+
+		rulePath := rule.LocalPath()
+		if rulePath == "" {
+			continue
+		}
+		tests, ok := fileTests[rulePath]
+		if !ok {
+			var err error
+			tests, err = config.GetRuleTests(rulePath)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", config.RuleTestPath(rulePath), err.Error())
+			}
+			fileTests[rulePath] = tests
+		}
+		for _, test := range tests {
+			if test.Rule == rule.Name || (test.Rule != "" && test.Rule == rule.Metadata.Id) {
+				cases = append(cases, ruleTestCase{rule: rule, test: test})
+			}
+		}
+	}
+	return cases, nil
+}
+
+// runRuleTest load the fixtures of test into a temporary schema, run the rule on it and check the matched rows
+func runRuleTest(ctx context.Context, rule config.Rule, test config.RuleTest, tables map[string]*schema.Table, variablesMap map[string]interface{}) error {
+	if test.Expect.Count == nil && test.Expect.Rows == nil {
+		return errors.New("expect count or rows must be declared")
+	}
+	schemaName := "selefra_rule_test_" + id_util.RandomId()
+	sto, diag := pgstorage.Storage(ctx, pgstorage.WithSearchPath(schemaName))
+	if diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	defer func() {
+		if diag := sto.Exec(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schemaName)); diag != nil && diag.HasError() {
+			ui.Errorln(diag.ToString())
+		}
+		sto.Close()
+	}()
+
+	if err := loadFixtures(ctx, sto, tables, test.Fixtures); err != nil {
+		return err
+	}
+	_, rows, err := apply.QueryRule(ctx, sto, rule, variablesMap)
+	if err != nil {
+		return err
+	}
+	return checkExpect(rows, test.Expect)
+}
+
+// checkExpect compare the rows matched by a rule with the expectation of its test
+func checkExpect(rows *schema.Rows, expect config.RuleTestExpect) error {
+	var matched []map[string]interface{}
+	columns := rows.GetColumnNames()
+	for _, row := range rows.GetMatrix() {
+		var rowMap = make(map[string]interface{})
+		for index, value := range row {
+			rowMap[columns[index]] = value
+		}
+		matched = append(matched, rowMap)
+	}
+
+	if expect.Count != nil && len(matched) != *expect.Count {
+		return fmt.Errorf("expect %d rows matched, got %d", *expect.Count, len(matched))
+	}
+	if expect.Rows == nil {
+		return nil
+	}
+	if expect.Count == nil && len(matched) != len(expect.Rows) {
+		return fmt.Errorf("expect %d rows matched, got %d", len(expect.Rows), len(matched))
+	}
+	used := make([]bool, len(matched))
+	for _, expectRow := range expect.Rows {
+		found := false
+		for i, row := range matched {
+			if !used[i] && rowContains(row, expectRow) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("expect row %s matched, but not found", utils.Strava(expectRow))
+		}
+	}
+	return nil
+}
+
+// rowContains return true if every column of expect has the same value in row
+func rowContains(row map[string]interface{}, expect map[string]interface{}) bool {
+	for column, value := range expect {
+		v, ok := row[column]
+		if !ok || utils.Strava(v) != utils.Strava(value) {
+			return false
+		}
+	}
+	return true
+}
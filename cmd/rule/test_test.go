@@ -0,0 +1,99 @@
+package rule
+
+import (
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-utils/pkg/pointer"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ruleTestCases(t *testing.T) {
+	global.Init("", global.WithWorkspace("../../tests/workspace/offline"))
+	rules, err := tools.LoadRules()
+	require.NoError(t, err)
+
+	cases, err := ruleTestCases(tools.FilterRules(rules, []string{"SF010302"}))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(cases))
+	require.Equal(t, "only unencrypted volumes are matched", cases[0].test.Name)
+	require.Equal(t, 2, len(cases[0].test.Fixtures["aws_ec2_ebs_volumes"]))
+
+	cases, err = ruleTestCases(tools.FilterRules(rules, []string{"not_exists"}))
+	require.NoError(t, err)
+	require.Equal(t, 0, len(cases))
+}
+
+func Test_checkExpect(t *testing.T) {
+	rows := schema.NewRows("id", "encrypted")
+	require.NoError(t, rows.AppendRowValues([]any{"vol-1", false}))
+	require.NoError(t, rows.AppendRowValues([]any{"vol-2", false}))
+
+	two := 2
+	one := 1
+	require.NoError(t, checkExpect(rows, config.RuleTestExpect{Count: &two}))
+	require.Error(t, checkExpect(rows, config.RuleTestExpect{Count: &one}))
+	require.NoError(t, checkExpect(rows, config.RuleTestExpect{Rows: []map[string]interface{}{{"id": "vol-2"}, {"id": "vol-1", "encrypted": false}}}))
+	require.Error(t, checkExpect(rows, config.RuleTestExpect{Rows: []map[string]interface{}{{"id": "vol-1"}}}))
+	require.Error(t, checkExpect(rows, config.RuleTestExpect{Rows: []map[string]interface{}{{"id": "vol-1"}, {"id": "vol-1"}}}))
+}
+
+func Test_fixtureColumns(t *testing.T) {
+	table := fixtureTable(&schema.Table{
+		TableName: "aws_ec2_ebs_volumes",
+		Columns: []*schema.Column{
+			{ColumnName: "id", Type: schema.ColumnTypeString, Options: schema.ColumnOptions{NotNull: pointer.TruePointer()}},
+			{ColumnName: "size", Type: schema.ColumnTypeBigInt},
+			{ColumnName: "encrypted", Type: schema.ColumnTypeBool},
+			{ColumnName: "tags", Type: schema.ColumnTypeJSON},
+		},
+		Options:   &schema.TableOptions{PrimaryKeys: []string{"id"}},
+		SubTables: []*schema.Table{{TableName: "aws_ec2_ebs_volume_attachments"}},
+	})
+	require.Nil(t, table.Options)
+	require.Empty(t, table.SubTables)
+	require.Nil(t, table.Columns[0].Options.NotNull)
+
+	columns, err := fixtureColumns(table, []map[string]interface{}{
+		{"id": "vol-1", "size": nil},
+		{"ID": "vol-2", "size": 10, "encrypted": true},
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.ColumnName)
+	}
+	require.Equal(t, []string{"encrypted", "id", "size"}, names)
+
+	_, err = fixtureColumns(table, []map[string]interface{}{{"id": "vol-1", "zone": "a"}})
+	require.ErrorContains(t, err, "column zone does not exist in table aws_ec2_ebs_volumes")
+}
+
+func Test_fixtureValue(t *testing.T) {
+	jsonColumn := &schema.Column{ColumnName: "tags", Type: schema.ColumnTypeJSON}
+	value, err := fixtureValue(jsonColumn, map[string]interface{}{"env": "prod"})
+	require.NoError(t, err)
+	require.Equal(t, `{"env":"prod"}`, value)
+	value, err = fixtureValue(jsonColumn, `{"env":"prod"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"env":"prod"}`, value)
+
+	arrayColumn := &schema.Column{ColumnName: "names", Type: schema.ColumnTypeStringArray}
+	value, err = fixtureValue(arrayColumn, []interface{}{"a", `b"c`, nil, 1})
+	require.NoError(t, err)
+	require.Equal(t, `{"a","b\"c",NULL,"1"}`, value)
+	_, err = fixtureValue(arrayColumn, "a")
+	require.Error(t, err)
+
+	boolColumn := &schema.Column{ColumnName: "encrypted", Type: schema.ColumnTypeBool}
+	value, err = fixtureValue(boolColumn, true)
+	require.NoError(t, err)
+	require.Equal(t, true, value)
+	_, err = fixtureValue(boolColumn, []interface{}{true})
+	require.Error(t, err)
+	value, err = fixtureValue(boolColumn, nil)
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
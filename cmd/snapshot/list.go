@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"errors"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/ui"
+	"github.com/selefra/selefra/ui/table"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+func newCmdSnapshotList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "list",
+		Short:            "List the current data and snapshots of every provider",
+		Long:             "List the current data and snapshots of every provider, the id or fetch time of a snapshot can be given to apply --snapshot and query --snapshot",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			rootConfig, err := config.GetConfig()
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+			sto, diag := pgstorage.Storage(ctx)
+			if diag != nil && diag.HasError() {
+				ui.Errorln(diag.ToString())
+				return errors.New(diag.ToString())
+			}
+			defer sto.Close()
+
+			var body [][]string
+			for _, ps := range tools.ProviderSchemas(rootConfig) {
+				fetchTime, ok, err := snapshot.FetchTime(ctx, sto, ps.Schema)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				if ok {
+					body = append(body, []string{ps.Provider.Name, snapshot.Current, fetchTime.Local().Format(time.RFC3339), ps.Schema})
+				}
+				snapshots, err := snapshot.List(ctx, sto, ps.Schema)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+				for _, s := range snapshots {
+					body = append(body, []string{ps.Provider.Name, s.Id, s.Time.Local().Format(time.RFC3339), s.Schema})
+				}
+			}
+			if len(body) == 0 {
+				ui.Warningln("No snapshot found")
+				return nil
+			}
+			table.ShowTable([]string{"Provider", "Snapshot", "Fetched At", "Schema"}, body, []string{}, true)
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
@@ -0,0 +1,60 @@
+package snapshot
+
+import (
+	"errors"
+	"github.com/selefra/selefra/cmd/tools"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/snapshot"
+	"github.com/selefra/selefra/ui"
+	"github.com/spf13/cobra"
+)
+
+func newCmdSnapshotPrune() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:              "prune",
+		Short:            "Drop the snapshots beyond the retention of every provider",
+		Long:             "Drop the snapshots beyond the retention of every provider, the retention is the snapshots in provider config unless --keep is given",
+		PersistentPreRun: global.DefaultWrappedInit(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			keep, _ := cmd.Flags().GetInt("keep")
+			rootConfig, err := config.GetConfig()
+			if err != nil {
+				ui.Errorln(err.Error())
+				return err
+			}
+			sto, diag := pgstorage.Storage(ctx)
+			if diag != nil && diag.HasError() {
+				ui.Errorln(diag.ToString())
+				return errors.New(diag.ToString())
+			}
+			defer sto.Close()
+
+			var total int
+			for _, ps := range tools.ProviderSchemas(rootConfig) {
+				n := ps.Provider.Snapshots
+				if cmd.Flags().Changed("keep") {
+					n = keep
+				}
+				pruned, err := snapshot.Prune(ctx, sto, ps.Schema, n)
+				for _, s := range pruned {
+					ui.Successf("Dropped snapshot %s of %s\n", s.Id, ps.Provider.Name)
+				}
+				total += len(pruned)
+				if err != nil {
+					ui.Errorln(err.Error())
+					return err
+				}
+			}
+			ui.Successf("%d snapshots pruned\n", total)
+			return nil
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().Int("keep", 0, "the number of newest snapshots to keep for every provider, default is the snapshots in provider config")
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
@@ -0,0 +1,18 @@
+package snapshot
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot [command]",
+		Short: "Top-level command to list and prune the snapshots of fetched resources",
+		Long:  "Top-level command to list and prune the snapshots of fetched resources, a provider keeps the data replaced by its latest fetches as snapshots when snapshots is set in its config",
+	}
+
+	cmd.AddCommand(newCmdSnapshotList(), newCmdSnapshotPrune())
+
+	cmd.SetHelpFunc(cmd.HelpFunc())
+	return cmd
+}
@@ -36,7 +36,11 @@ func TestFunc(ctx context.Context) error {
 	if err != nil {
 		ui.Errorln("GetWDError:" + err.Error())
 	}
-	return CheckSelefraConfig(ctx, rootConfig)
+	if err := CheckSelefraConfig(ctx, rootConfig); err != nil {
+		return err
+	}
+	// the tables of the installed providers are checked, test does not depend on fetched data
+	return tools.LintRules(ctx, nil, tools.InstalledTables)
 }
 
 func testFunc(cmd *cobra.Command, args []string) error {
@@ -79,7 +83,12 @@ func CheckSelefraConfig(ctx context.Context, s *config.RootConfig) error {
 				continue
 			}
 
-			storageOpt := pgstorage.DefaultPgStorageOpts()
+			storageOpt, err := pgstorage.DefaultPgStorageOpts()
+			if err != nil {
+				hasError = true
+				ui.Errorln(err.Error())
+				continue
+			}
 			opt, err := json.Marshal(storageOpt)
 
 			provider := plug.Provider()
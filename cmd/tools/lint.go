@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/rulelint"
+	"github.com/selefra/selefra/pkg/utils"
+	"github.com/selefra/selefra/ui"
+	"strings"
+)
+
+// TableLister list the columns of the provider tables that rules are checked against, keyed by lower case
+// table name and then by lower case column name
+type TableLister func(ctx context.Context, rootConfig *config.RootConfig) (map[string]map[string]bool, error)
+
+// LintRules check the rules whose name or id in names, all rules are checked when names is empty,
+// the tables and columns are checked against the tables listed by listTables, they are not checked when it is nil
+func LintRules(ctx context.Context, names []string, listTables TableLister) error {
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	rules, err := LoadRules()
+	if err != nil {
+		ui.Errorln(err.Error())
+		return err
+	}
+	rules = FilterRules(rules, names)
+
+	var tables map[string]map[string]bool
+	if listTables != nil {
+		tables, err = listTables(ctx, rootConfig)
+		if err != nil {
+			ui.Warningln("List provider tables error, table and column checks are skipped: " + err.Error())
+		} else if len(tables) == 0 {
+			ui.Warningln("No provider table found, table and column checks are skipped")
+		}
+	}
+
+	issues := rulelint.Lint(rules, VariablesMap(rootConfig), tables)
+	var errorCount int
+	for _, issue := range issues {
+		if issue.Level == rulelint.LevelError {
+			errorCount++
+			ui.Errorln(issue.String())
+		} else {
+			ui.Warningln(issue.String())
+		}
+	}
+	ui.Successf("Rule lint completed, %d rules checked, %d errors, %d warnings\n\n", len(rules), errorCount, len(issues)-errorCount)
+	if errorCount > 0 {
+		return fmt.Errorf("%d rule lint errors found", errorCount)
+	}
+	return nil
+}
+
+// FetchedTables return the columns of every table fetched by the providers of workspace
+func FetchedTables(ctx context.Context, rootConfig *config.RootConfig) (map[string]map[string]bool, error) {
+	var tables = make(map[string]map[string]bool)
+	for _, ps := range ProviderSchemas(rootConfig) {
+		sto, diag := pgstorage.Storage(ctx, pgstorage.WithSearchPaths(ps.Schema))
+		if diag != nil && diag.HasError() {
+			return nil, errors.New(diag.ToString())
+		}
+		schemaTables, diag := sto.TableList(ctx, ps.Schema)
+		sto.Close()
+		if diag != nil && diag.HasError() {
+			return nil, errors.New(diag.ToString())
+		}
+		addTables(tables, schemaTables)
+	}
+	return tables, nil
+}
+
+// InstalledTables return the columns of every table of the installed provider binaries of workspace
+func InstalledTables(ctx context.Context, rootConfig *config.RootConfig) (map[string]map[string]bool, error) {
+	binaryTables, err := InstalledProviderTables(ctx, rootConfig)
+	if err != nil {
+		return nil, err
+	}
+	var tables = make(map[string]map[string]bool)
+	addTables(tables, binaryTables)
+	return tables, nil
+}
+
+// InstalledProviderTables return the tables of the installed provider binaries of workspace and their sub tables,
+// the versions are resolved by the lock file like sync does and nothing needs to be fetched
+func InstalledProviderTables(ctx context.Context, rootConfig *config.RootConfig) ([]*schema.Table, error) {
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		return nil, err
+	}
+	var tables []*schema.Table
+	for _, decl := range rootConfig.Selefra.ProviderDecls {
+		resolved := ResolveDecl(decl, lock)
+		path := resolved.Path
+		if path == "" {
+			path = utils.GetPathBySource(*resolved.Source, resolved.Version)
+		}
+		binaryTables, err := BinaryTables(ctx, path, *resolved.Source, resolved.Version)
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: %s", resolved.Name, resolved.Version, err.Error())
+		}
+		tables = append(tables, binaryTables...)
+	}
+	return tables, nil
+}
+
+func addTables(tables map[string]map[string]bool, schemaTables []*schema.Table) {
+	for _, table := range schemaTables {
+		name := strings.ToLower(table.TableName)
+		if tables[name] == nil {
+			tables[name] = make(map[string]bool)
+		}
+		for _, column := range table.Columns {
+			tables[name][strings.ToLower(column.ColumnName)] = true
+		}
+	}
+}
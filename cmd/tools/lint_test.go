@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstalledTables(t *testing.T) {
+	global.Init("", global.WithWorkspace("../../tests/workspace/offline"))
+	source := "selefra/missing"
+	rootConfig := &config.RootConfig{}
+	rootConfig.Selefra.ProviderDecls = []*config.ProviderDecl{
+		{Name: "missing", Source: &source, Version: "v0.0.1", Path: filepath.Join(t.TempDir(), "selefra-provider-missing")},
+	}
+	_, err := InstalledTables(context.Background(), rootConfig)
+	require.ErrorContains(t, err, "missing@v0.0.1")
+
+	tables, err := InstalledTables(context.Background(), &config.RootConfig{})
+	require.NoError(t, err)
+	require.Empty(t, tables)
+}
+
+func TestAddTables(t *testing.T) {
+	var tables = make(map[string]map[string]bool)
+	addTables(tables, []*schema.Table{
+		{TableName: "AWS_S3_Buckets", Columns: []*schema.Column{{ColumnName: "ARN"}, {ColumnName: "name"}}},
+	})
+	addTables(tables, []*schema.Table{
+		{TableName: "aws_s3_buckets", Columns: []*schema.Column{{ColumnName: "region"}}},
+	})
+	require.Equal(t, map[string]map[string]bool{"aws_s3_buckets": {"arn": true, "name": true, "region": true}}, tables)
+}
@@ -3,15 +3,20 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
 	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/pgstorage"
 	"github.com/selefra/selefra/pkg/registry"
+	"github.com/selefra/selefra/pkg/snapshot"
 	"github.com/selefra/selefra/pkg/utils"
 	"github.com/selefra/selefra/ui"
 	"gopkg.in/yaml.v3"
 	"os"
-	"strconv"
+	"path"
+	"sort"
 	"strings"
 	"time"
 )
@@ -59,6 +64,65 @@ func ProvidersByID(rootConfig *config.RootConfig, id string) []*config.Provider
 	return prvds
 }
 
+// ProviderSchema is the schema of a configured provider
+type ProviderSchema struct {
+	Schema   string
+	Decl     *config.ProviderDecl
+	Provider *config.Provider
+}
+
+// ProviderSchemas return the schemas of all providers in rootConfig, the versions of the decls are resolved
+// by the lock file of workspace like sync does, so the schemas are the ones the providers are fetched into
+func ProviderSchemas(rootConfig *config.RootConfig) []ProviderSchema {
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		ui.Errorln(err.Error())
+	}
+	var schemas []ProviderSchema
+	for _, decl := range rootConfig.Selefra.ProviderDecls {
+		resolved := ResolveDecl(decl, lock)
+		for _, prvd := range ProvidersByID(rootConfig, decl.Name) {
+			schemas = append(schemas, ProviderSchema{
+				Schema:   config.GetSchemaKey(resolved, *prvd),
+				Decl:     resolved,
+				Provider: prvd,
+			})
+		}
+	}
+	return schemas
+}
+
+// ResolvedVersion return the version locked or installed for the version declared by decl, latest and constraints
+// are resolved when the provider is synced, it is "-" if the provider is not installed
+func ResolvedVersion(decl *config.ProviderDecl, lock *lockfile.LockFile) string {
+	if decl.Version != "" && decl.Version != "latest" && !registry.IsConstraint(decl.Version) {
+		return decl.Version
+	}
+	if lock != nil {
+		if locked := lock.Provider(decl.Name); locked != nil && locked.Constraint == decl.Version {
+			return locked.Version
+		}
+	}
+	if decl.Source == nil {
+		return "-"
+	}
+	if v := registry.InstalledVersion(utils.GetPathBySource(*decl.Source, decl.Version)); v != "" {
+		return v
+	}
+	return "-"
+}
+
+// ResolveDecl return a copy of decl whose version is resolved, decl is returned if its version is not resolved
+func ResolveDecl(decl *config.ProviderDecl, lock *lockfile.LockFile) *config.ProviderDecl {
+	version := ResolvedVersion(decl, lock)
+	if version == "-" || version == decl.Version {
+		return decl
+	}
+	resolved := *decl
+	resolved.Version = version
+	return &resolved
+}
+
 // SetProviderTmpl set the provider yaml template
 func SetProviderTmpl(template string, provider registry.ProviderBinary, config *config.RootConfig) error {
 	if config.Providers.Kind != yaml.SequenceNode {
@@ -136,7 +200,7 @@ func SetProviderTmpl(template string, provider registry.ProviderBinary, config *
 
 // AppendProviderDecl append a provider declare for rootConfig.Selefra.ProviderDecls
 func AppendProviderDecl(provider registry.ProviderBinary, rootConfig *config.RootConfig, configVersion string) error {
-	source, latestSource := utils.CreateSource(provider.Name, provider.Version, configVersion)
+	source, configSource := utils.CreateSource(provider.Name, provider.Version, configVersion)
 	_, configPath, err := utils.Home()
 	if err != nil {
 		ui.Errorln("SetSelefraProviderError: " + err.Error())
@@ -150,8 +214,8 @@ func AppendProviderDecl(provider registry.ProviderBinary, rootConfig *config.Roo
 		return err
 	}
 	json.Unmarshal(file, &pathMap)
-	if latestSource != "" {
-		pathMap[latestSource] = provider.Filepath
+	if configSource != "" {
+		pathMap[configSource] = provider.Filepath
 	}
 
 	pathMap[source] = provider.Filepath
@@ -173,6 +237,15 @@ func AppendProviderDecl(provider registry.ProviderBinary, rootConfig *config.Roo
 	return nil
 }
 
+// RegistryOptions return the options of the registry to download providers from configured in the selefra block
+func RegistryOptions(selefra config.SelefraConfig) []registry.Option {
+	return []registry.Option{
+		registry.WithURL(selefra.Registry),
+		registry.WithPublicKey(selefra.Verify.PublicKey),
+		registry.WithSkipVerify(selefra.Verify.Skip),
+	}
+}
+
 // CacheExpired check whether the cache time expires
 func CacheExpired(ctx context.Context, storage *postgresql_storage.PostgresqlStorage, cacheTime string) (bool, error) {
 	requireKey := config.GetCacheKey()
@@ -185,7 +258,7 @@ func CacheExpired(ctx context.Context, storage *postgresql_storage.PostgresqlSto
 		return true, err
 	}
 
-	duration, err := parseDuration(cacheTime)
+	duration, err := config.ParseCache(cacheTime)
 	if err != nil || duration == 0 {
 		return true, err
 	}
@@ -196,24 +269,119 @@ func CacheExpired(ctx context.Context, storage *postgresql_storage.PostgresqlSto
 	return false, nil
 }
 
-func parseDuration(d string) (time.Duration, error) {
-	d = strings.TrimSpace(d)
-	dr, err := time.ParseDuration(d)
-	if err == nil {
-		return dr, nil
+// ExpiredTables return the root tables of a provider instance whose cache time expires, a resource with its own cache time
+// uses it instead of the cache time of provider. all is true if every table expires and the whole instance should be fetched.
+// the root tables are read from the provider binary of decl, so the tables never fetched are expired too
+func ExpiredTables(ctx context.Context, storage *postgresql_storage.PostgresqlStorage, decl *config.ProviderDecl, prvd *config.Provider) (tables []string, all bool, err error) {
+	rows, diag := storage.ListKey(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, true, errors.New(diag.ToString())
 	}
-	if strings.Contains(d, "d") {
-		index := strings.Index(d, "d")
-
-		hour, _ := strconv.Atoi(d[:index])
-		dr = time.Hour * 24 * time.Duration(hour)
-		ndr, err := time.ParseDuration(d[index+1:])
+	var fetchTimes = make(map[string]time.Time)
+	for _, row := range rows.GetMatrix() {
+		key := utils.Strava(row[0])
+		if !strings.HasPrefix(key, snapshot.TableFetchTimeKeyPrefix) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, utils.Strava(row[1]))
 		if err != nil {
-			return dr, nil
+			continue
 		}
-		return dr + ndr, nil
+		fetchTimes[strings.TrimPrefix(key, snapshot.TableFetchTimeKeyPrefix)] = t
 	}
+	if len(fetchTimes) == 0 {
+		// the data fetched by an older version has no table fetch time
+		expired, err := CacheExpired(ctx, storage, prvd.Cache)
+		return nil, expired, err
+	}
+	pluginPath := decl.Path
+	if pluginPath == "" {
+		pluginPath = utils.GetPathBySource(*decl.Source, decl.Version)
+	}
+	roots, err := binaryRoots(ctx, pluginPath, *decl.Source, decl.Version)
+	if err != nil {
+		return nil, true, err
+	}
+	var rootNames []string
+	for name := range roots {
+		rootNames = append(rootNames, name)
+	}
+	tables, all = expiredTables(prvd, rootNames, fetchTimes, time.Now())
+	return tables, all, nil
+}
+
+// expiredTables return the tables expired at now, roots are the root tables of the provider, a resource may be a pattern
+// such as aws_* which matches many tables. the tables fetched but no longer configured are skipped and the tables
+// configured but not fetched yet, including the ones matched by a pattern, are expired
+func expiredTables(prvd *config.Provider, roots []string, fetchTimes map[string]time.Time, now time.Time) (tables []string, all bool) {
+	var candidates []string
+	for table := range fetchTimes {
+		if len(prvd.Resources) == 0 || matchResource(prvd.Resources, table) != nil {
+			candidates = append(candidates, table)
+		}
+	}
+	for _, table := range roots {
+		if len(prvd.Resources) == 0 || matchResource(prvd.Resources, table) != nil {
+			candidates = append(candidates, table)
+		}
+	}
+	for _, r := range prvd.Resources {
+		if _, ok := fetchTimes[r.Name]; !ok && !strings.ContainsAny(r.Name, "*?[") {
+			candidates = append(candidates, r.Name)
+		}
+	}
+
+	// the cache times are validated when the config is loaded
+	defaultCache, _ := config.ParseCache(prvd.Cache)
+	var seen = make(map[string]bool)
+	for _, table := range candidates {
+		if seen[table] {
+			continue
+		}
+		seen[table] = true
+		cache := defaultCache
+		if r := matchResource(prvd.Resources, table); r != nil && r.Cache != "" {
+			cache, _ = config.ParseCache(r.Cache)
+		}
+		fetchTime, ok := fetchTimes[table]
+		if !ok || cache == 0 || now.Sub(fetchTime) > cache {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables, len(tables) == len(seen)
+}
+
+// OverrideResources return a copy of prvd which fetches the resources instead of the resources in its config,
+// a resource keeps the cache time configured for it
+func OverrideResources(prvd *config.Provider, resources []string) *config.Provider {
+	override := *prvd
+	override.Resources = nil
+	for _, name := range resources {
+		r := config.ProviderResource{Name: name}
+		if matched := matchResource(prvd.Resources, name); matched != nil {
+			r.Cache = matched.Cache
+		}
+		override.Resources = append(override.Resources, r)
+	}
+	return &override
+}
+
+// matchResource return the resource which matches table, the resource named table takes precedence over patterns
+func matchResource(resources []config.ProviderResource, table string) *config.ProviderResource {
+	var matched *config.ProviderResource
+	for i := range resources {
+		if resources[i].Name == table {
+			return &resources[i]
+		}
+		if ok, _ := path.Match(resources[i].Name, table); ok && matched == nil {
+			matched = &resources[i]
+		}
+	}
+	return matched
+}
 
-	dv, err := strconv.ParseInt(d, 10, 64)
-	return time.Duration(dv), err
+// CacheDuration parse the cache time of a provider, such as 1d, 12h or 1d12h
+func CacheDuration(cacheTime string) (time.Duration, error) {
+	return config.ParseCache(cacheTime)
 }
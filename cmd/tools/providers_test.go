@@ -4,11 +4,13 @@ import (
 	"context"
 	"github.com/selefra/selefra/config"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/registry"
 	"github.com/selefra/selefra/pkg/utils"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 	"testing"
+	"time"
 )
 
 func getProviderAndConfig() (registry.ProviderBinary, *config.RootConfig, error) {
@@ -106,3 +108,95 @@ func TestSetSelefraProvider(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestExpiredTables(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	fetchTimes := map[string]time.Time{
+		"aws_ec2_instances": now.Add(-2 * time.Hour),
+		"aws_s3_buckets":    now.Add(-2 * time.Hour),
+		"aws_iam_users":     now.Add(-30 * time.Minute),
+	}
+	roots := []string{"aws_ec2_instances", "aws_iam_roles", "aws_iam_users", "aws_s3_access_points", "aws_s3_buckets"}
+
+	prvd := &config.Provider{Cache: "1d"}
+	tables, all := expiredTables(prvd, []string{"aws_ec2_instances", "aws_iam_users", "aws_s3_buckets"}, fetchTimes, now)
+	require.Empty(t, tables)
+	require.False(t, all)
+
+	// the roots never fetched are expired
+	tables, all = expiredTables(prvd, roots, fetchTimes, now)
+	require.Equal(t, []string{"aws_iam_roles", "aws_s3_access_points"}, tables)
+	require.False(t, all)
+
+	prvd.Resources = []config.ProviderResource{
+		{Name: "aws_ec2_instances", Cache: "1h"},
+		{Name: "aws_s3_buckets"},
+		{Name: "aws_iam_users", Cache: "1h"},
+		{Name: "aws_iam_roles"},
+	}
+	tables, all = expiredTables(prvd, roots, fetchTimes, now)
+	require.Equal(t, []string{"aws_ec2_instances", "aws_iam_roles"}, tables)
+	require.False(t, all)
+
+	prvd.Resources = []config.ProviderResource{
+		{Name: "aws_ec2_*", Cache: "1h"},
+		{Name: "aws_iam_*"},
+	}
+	tables, all = expiredTables(prvd, roots, fetchTimes, now)
+	require.Equal(t, []string{"aws_ec2_instances", "aws_iam_roles"}, tables)
+	require.False(t, all)
+
+	// a pattern matching a root without fetch time expires it, the fetched ones keep their cache
+	prvd.Resources = []config.ProviderResource{{Name: "aws_s3_*"}}
+	tables, all = expiredTables(prvd, roots, fetchTimes, now)
+	require.Equal(t, []string{"aws_s3_access_points"}, tables)
+	require.False(t, all)
+
+	prvd = &config.Provider{}
+	tables, all = expiredTables(prvd, roots, fetchTimes, now)
+	require.Equal(t, roots, tables)
+	require.True(t, all)
+}
+
+func TestOverrideResources(t *testing.T) {
+	prvd := &config.Provider{
+		Name:  "aws_01",
+		Cache: "1d",
+		Resources: []config.ProviderResource{
+			{Name: "aws_ec2_*", Cache: "1h"},
+			{Name: "aws_s3_buckets", Cache: "2h"},
+		},
+	}
+	override := OverrideResources(prvd, []string{"aws_s3_buckets", "aws_ec2_instances", "aws_iam_users"})
+	require.Equal(t, []config.ProviderResource{
+		{Name: "aws_s3_buckets", Cache: "2h"},
+		{Name: "aws_ec2_instances", Cache: "1h"},
+		{Name: "aws_iam_users"},
+	}, override.Resources)
+	require.Equal(t, "1d", override.Cache)
+	require.Len(t, prvd.Resources, 2)
+}
+
+func TestResolveDecl(t *testing.T) {
+	lock, err := lockfile.Load(t.TempDir())
+	require.NoError(t, err)
+	lock.SetProvider(&lockfile.Provider{Name: "aws", Source: "selefra/aws", Constraint: "~> 0.0.12", Version: "v0.0.13"})
+	source := "selefra/aws"
+	decl := &config.ProviderDecl{Name: "aws", Source: &source, Version: "~> 0.0.12"}
+	prvd := config.Provider{Name: "aws_prod"}
+
+	// sync fetches into the schema of the version it downloaded
+	synced := &config.ProviderDecl{Name: "aws", Source: &source, Version: "v0.0.13"}
+	resolved := ResolveDecl(decl, lock)
+	require.Equal(t, "v0.0.13", resolved.Version)
+	require.Equal(t, "~> 0.0.12", decl.Version)
+	require.Equal(t, config.GetSchemaKey(synced, prvd), config.GetSchemaKey(resolved, prvd))
+	require.Equal(t, "aws_v0013_aws_prod", config.GetSchemaKey(resolved, prvd))
+
+	// a pinned version is kept
+	require.Same(t, synced, ResolveDecl(synced, lock))
+	// a constraint changed since locked is not resolved by the lock
+	changed := &config.ProviderDecl{Name: "aws", Source: &source, Version: "~> 0.1.0"}
+	require.Equal(t, "~> 0.1.0", ResolveDecl(changed, nil).Version)
+	require.Equal(t, "~> 0.1.0", ResolveDecl(changed, lock).Version)
+}
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/ui"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// VariablesMap return the default values of the variables declared in workspace, used to render rule templates
+func VariablesMap(rootConfig *config.RootConfig) map[string]interface{} {
+	var variablesMap = make(map[string]interface{})
+	for i := range rootConfig.Variables {
+		variablesMap[rootConfig.Variables[i].Key] = rootConfig.Variables[i].Default
+	}
+	return variablesMap
+}
+
+// FilterRules return the rules whose name or id in names, all rules are returned when names is empty
+func FilterRules(rules []config.Rule, names []string) []config.Rule {
+	if len(names) == 0 {
+		return rules
+	}
+	var filtered []config.Rule
+	for _, rule := range rules {
+		for _, name := range names {
+			if rule.Name == name || rule.Metadata.Id == name {
+				filtered = append(filtered, rule)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// RuleFilter select rules by id or name, tag, severity, provider and module, values prefixed with ! exclude the matched rules.
+// a rule is selected when it matches any included value and no excluded value of every filter
+type RuleFilter struct {
+	Rules      []string
+	Tags       []string
+	Severities []string
+	Providers  []string
+	Modules    []string
+}
+
+// Filter return the rules selected by the filter
+func (f RuleFilter) Filter(rules []config.Rule) []config.Rule {
+	var filtered []config.Rule
+	for _, rule := range rules {
+		if f.Match(rule) {
+			filtered = append(filtered, rule)
+		}
+	}
+	return filtered
+}
+
+// Match return true if the rule is selected by the filter
+func (f RuleFilter) Match(rule config.Rule) bool {
+	return matchRuleFilter(f.Rules, func(v string) bool {
+		return strings.EqualFold(rule.Metadata.Id, v) || rule.Name == v
+	}) && matchRuleFilter(f.Tags, func(v string) bool {
+		for _, tag := range rule.Metadata.Tags {
+			if strings.EqualFold(tag, v) {
+				return true
+			}
+		}
+		return false
+	}) && matchRuleFilter(f.Severities, func(v string) bool {
+		return strings.EqualFold(rule.Metadata.Severity, v)
+	}) && matchRuleFilter(f.Providers, func(v string) bool {
+		return strings.EqualFold(rule.Metadata.Provider, v)
+	}) && matchRuleFilter(f.Modules, func(v string) bool {
+		// a module also selects the rules of its child modules, whose names are prefixed with the parent name and a dot
+		return rule.Module == v || strings.HasPrefix(rule.Module, v+".")
+	})
+}
+
+// IsEmpty return true if the filter selects all rules
+func (f RuleFilter) IsEmpty() bool {
+	return len(f.Rules)+len(f.Tags)+len(f.Severities)+len(f.Providers)+len(f.Modules) == 0
+}
+
+func matchRuleFilter(values []string, match func(v string) bool) bool {
+	included := false
+	hasInclude := false
+	for _, value := range values {
+		if strings.HasPrefix(value, "!") {
+			if match(strings.TrimPrefix(value, "!")) {
+				return false
+			}
+			continue
+		}
+		hasInclude = true
+		if match(value) {
+			included = true
+		}
+	}
+	return !hasInclude || included
+}
+
+// LoadRules load the rules used by modules, all rules in workspace are loaded when there is no module
+func LoadRules() ([]config.Rule, error) {
+	modules, err := config.GetModules()
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) == 0 {
+		return GetAllRules(), nil
+	}
+	return GetRules(modules), nil
+}
+
+// GetAllRules get all rules from workspace
+func GetAllRules() []config.Rule {
+	rules, _ := config.GetRules()
+	for i := range rules.Rules {
+		if strings.HasPrefix(rules.Rules[i].Query, ".") {
+			sqlByte, err := os.ReadFile(filepath.Join(".", rules.Rules[i].Query))
+			if err != nil {
+				ui.Errorf("sql open error:%s", err.Error())
+				return nil
+			}
+			rules.Rules[i].Query = string(sqlByte)
+		}
+	}
+	return rules.Rules
+}
+
+// GetRules find all rules in modules
+func GetRules(modules []config.Module) []config.Rule {
+	var rules []config.Rule
+	for _, module := range modules {
+		if rule := GetModuleRules(module); rule != nil {
+			rules = append(rules, rule...)
+		}
+	}
+	return rules
+}
+
+// GetModuleRules find all rules according to given module
+func GetModuleRules(module config.Module) []config.Rule {
+	var resRule config.RuleSet
+	var b []byte
+	var err error
+
+	for _, use := range module.Uses {
+		var usePath string
+		if path.IsAbs(use) || strings.Index(use, "://") > -1 {
+			usePath = use
+		} else {
+			usePath = filepath.Join(global.WorkSpace(), use)
+		}
+		if strings.Index(usePath, "://") > -1 {
+			d := config.Downloader{Url: usePath}
+			b, err = d.Get()
+			if err != nil {
+				ui.Errorln(err.Error())
+				return nil
+			}
+		} else {
+			b, err = os.ReadFile(usePath)
+			if err != nil {
+				ui.Errorln(err.Error())
+				return nil
+			}
+		}
+
+		var baseRule config.RuleSet
+		err = yaml.Unmarshal(b, &baseRule)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return nil
+		}
+
+		if err != nil {
+			ui.Errorln(err.Error())
+			return nil
+		}
+		var ruleConfig config.RuleSet
+		err = yaml.Unmarshal([]byte(string(b)), &ruleConfig)
+		if err != nil {
+			ui.Errorln(err.Error())
+			return nil
+		}
+		for i := range ruleConfig.Rules {
+			ruleConfig.Rules[i].Output = baseRule.Rules[i].Output
+			ruleConfig.Rules[i].Query = baseRule.Rules[i].Query
+			ruleConfig.Rules[i].Path = use
+			ruleConfig.Rules[i].Module = module.Name
+			_, err := os.Stat(filepath.Join(global.WorkSpace(), ruleConfig.Rules[i].Query))
+			if err == nil {
+				var sqlPath string
+				if filepath.IsAbs(ruleConfig.Rules[i].Query) {
+					sqlPath = ruleConfig.Rules[i].Query
+				} else {
+					sqlPath = filepath.Join(global.WorkSpace(), ruleConfig.Rules[i].Query)
+				}
+				sqlByte, err := os.ReadFile(sqlPath)
+				if err != nil {
+					ui.Errorf("sql open error:%s", err.Error())
+					return nil
+				}
+				ruleConfig.Rules[i].Query = string(sqlByte)
+			}
+			ui.Successf("	%s - Rule %s: loading ... \n", use, baseRule.Rules[i].Name)
+		}
+		resRule.Rules = append(resRule.Rules, ruleConfig.Rules...)
+	}
+	return resRule.Rules
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"github.com/selefra/selefra/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func testFilterRules() []config.Rule {
+	var rules = make([]config.Rule, 4)
+	rules[0].Name, rules[0].Metadata.Id, rules[0].Metadata.Severity, rules[0].Metadata.Provider = "ebs_unencrypted", "SF010302", "Low", "AWS"
+	rules[0].Metadata.Tags, rules[0].Module = []string{"Security", "Misconfigure"}, "aws"
+	rules[1].Name, rules[1].Metadata.Id, rules[1].Metadata.Severity, rules[1].Metadata.Provider = "s3_public", "SF010101", "High", "AWS"
+	rules[1].Metadata.Tags, rules[1].Module = []string{"Security"}, "aws.s3"
+	rules[2].Name, rules[2].Metadata.Id, rules[2].Metadata.Severity, rules[2].Metadata.Provider = "gcp_bucket_public", "SF020101", "Critical", "GCP"
+	rules[2].Module = "gcp"
+	rules[3].Name, rules[3].Metadata.Severity, rules[3].Metadata.Provider = "no_module", "Medium", "AWS"
+	return rules
+}
+
+func filteredNames(rules []config.Rule) []string {
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func TestRuleFilter(t *testing.T) {
+	rules := testFilterRules()
+	cases := []struct {
+		filter RuleFilter
+		expect []string
+	}{
+		{RuleFilter{}, []string{"ebs_unencrypted", "s3_public", "gcp_bucket_public", "no_module"}},
+		{RuleFilter{Rules: []string{"sf010302", "no_module"}}, []string{"ebs_unencrypted", "no_module"}},
+		{RuleFilter{Rules: []string{"!SF010302"}}, []string{"s3_public", "gcp_bucket_public", "no_module"}},
+		{RuleFilter{Tags: []string{"misconfigure"}}, []string{"ebs_unencrypted"}},
+		{RuleFilter{Severities: []string{"high", "critical"}}, []string{"s3_public", "gcp_bucket_public"}},
+		{RuleFilter{Providers: []string{"aws"}, Severities: []string{"!low"}}, []string{"s3_public", "no_module"}},
+		{RuleFilter{Modules: []string{"aws"}}, []string{"ebs_unencrypted", "s3_public"}},
+		{RuleFilter{Modules: []string{"aws", "!aws.s3"}}, []string{"ebs_unencrypted"}},
+		{RuleFilter{Modules: []string{"aw"}}, nil},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, filteredNames(c.filter.Filter(rules)), "%+v", c.filter)
+	}
+	require.True(t, RuleFilter{}.IsEmpty())
+	require.False(t, RuleFilter{Tags: []string{"Security"}}.IsEmpty())
+}
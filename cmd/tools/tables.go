@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/selefra/selefra-provider-sdk/grpc/shard"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/selefra/selefra-utils/pkg/pointer"
+	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/pgstorage"
+	"github.com/selefra/selefra/pkg/plugin"
+	"sort"
+)
+
+// BinaryTables return the tables of the provider binary at path and their sub tables sorted by name
+func BinaryTables(ctx context.Context, path, name, version string) ([]*schema.Table, error) {
+	roots, err := binaryRoots(ctx, path, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return FlatTables(roots), nil
+}
+
+// binaryRoots return the root tables of the provider binary at path keyed by name. the provider sdk connects
+// the storage on init, so the provider is initialized with the configured storage, no table is created in it
+func binaryRoots(ctx context.Context, path, name, version string) (map[string]*schema.Table, error) {
+	plug, err := plugin.NewManagedPlugin(path, name, version, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer plug.Close()
+
+	plugProvider := plug.Provider()
+	storageOpt, err := pgstorage.DefaultPgStorageOpts()
+	if err != nil {
+		return nil, err
+	}
+	opt, err := json.Marshal(storageOpt)
+	if err != nil {
+		return nil, err
+	}
+	initRes, err := plugProvider.Init(ctx, &shard.ProviderInitRequest{
+		Workspace: pointer.ToStringPointer(global.WorkSpace()),
+		Storage: &shard.Storage{
+			Type:           0,
+			StorageOptions: opt,
+		},
+		IsInstallInit:  pointer.FalsePointer(),
+		ProviderConfig: pointer.ToStringPointer(""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if initRes != nil && initRes.Diagnostics != nil && initRes.Diagnostics.HasError() {
+		return nil, errors.New(initRes.Diagnostics.ToString())
+	}
+	res, err := plugProvider.GetProviderInformation(ctx, &shard.GetProviderInformationRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if res.Diagnostics != nil && res.Diagnostics.HasError() {
+		return nil, errors.New(res.Diagnostics.ToString())
+	}
+	return res.Tables, nil
+}
+
+// FlatTables return the tables and their sub tables sorted by name, a table is returned once
+func FlatTables(roots map[string]*schema.Table) []*schema.Table {
+	var tables []*schema.Table
+	var seen = make(map[string]bool)
+	var walk func(t *schema.Table)
+	walk = func(t *schema.Table) {
+		if seen[t.TableName] {
+			return
+		}
+		seen[t.TableName] = true
+		tables = append(tables, t)
+		for _, sub := range t.SubTables {
+			walk(sub)
+		}
+	}
+	for _, t := range roots {
+		walk(t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].TableName < tables[j].TableName
+	})
+	return tables
+}
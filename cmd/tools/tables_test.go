@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"github.com/selefra/selefra-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestBinaryTables(t *testing.T) {
+	_, err := BinaryTables(context.Background(), filepath.Join(t.TempDir(), "selefra-provider-missing"), "missing", "v0.0.1")
+	require.Error(t, err)
+}
+
+func TestFlatTables(t *testing.T) {
+	grants := &schema.Table{TableName: "aws_s3_bucket_grants"}
+	roots := map[string]*schema.Table{
+		"aws_s3_buckets": {
+			TableName: "aws_s3_buckets",
+			SubTables: []*schema.Table{grants, {TableName: "aws_s3_bucket_policies", SubTables: []*schema.Table{grants}}},
+		},
+		"aws_ec2_instances": {TableName: "aws_ec2_instances"},
+	}
+	var names []string
+	for _, table := range FlatTables(roots) {
+		names = append(names, table.TableName)
+	}
+	require.Equal(t, []string{"aws_ec2_instances", "aws_s3_bucket_grants", "aws_s3_bucket_policies", "aws_s3_buckets"}, names)
+	require.Empty(t, FlatTables(nil))
+}
@@ -12,7 +12,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/selefra/selefra/global"
 )
@@ -20,29 +22,120 @@ import (
 type sectionName string
 
 const (
-	SELEFRA   sectionName = "selefra"
-	MODULES   sectionName = "modules"
-	PROVIDERS sectionName = "providers"
-	VARIABLES sectionName = "variables"
-	RULES     sectionName = "rules"
+	SELEFRA      sectionName = "selefra"
+	MODULES      sectionName = "modules"
+	PROVIDERS    sectionName = "providers"
+	VARIABLES    sectionName = "variables"
+	RULES        sectionName = "rules"
+	SUPPRESSIONS sectionName = "suppressions"
 )
 
 var typeMap = map[sectionName]bool{
-	SELEFRA:   true,
-	MODULES:   true,
-	PROVIDERS: true,
-	RULES:     true,
-	VARIABLES: true,
+	SELEFRA:      true,
+	MODULES:      true,
+	PROVIDERS:    true,
+	RULES:        true,
+	VARIABLES:    true,
+	SUPPRESSIONS: true,
 }
 
 // Provider is provider config
 type Provider struct {
-	Name          string   `yaml:"name" json:"name"`
-	Cache         string   `yaml:"cache" json:"cache"`
-	Provider      string   `yaml:"provider" json:"provider"`
-	MaxGoroutines uint64   `yaml:"max_goroutines" json:"max_goroutines"`
-	Resources     []string `yaml:"resources" json:"resources"`
-	LogLevel      string   `yaml:"log_level" json:"log_level"`
+	Name          string             `yaml:"name" json:"name"`
+	Cache         string             `yaml:"cache" json:"cache"`
+	Provider      string             `yaml:"provider" json:"provider"`
+	MaxGoroutines uint64             `yaml:"max_goroutines" json:"max_goroutines"`
+	Resources     []ProviderResource `yaml:"resources" json:"resources"`
+	LogLevel      string             `yaml:"log_level" json:"log_level"`
+	Snapshots     int                `yaml:"snapshots" json:"snapshots"`
+}
+
+// ProviderResource is a resource of provider to fetch, it is written as the table name,
+// or as a map with its own cache time which overrides the cache time of provider
+type ProviderResource struct {
+	Name  string `yaml:"name" json:"name"`
+	Cache string `yaml:"cache,omitempty" json:"cache,omitempty"`
+}
+
+// UnmarshalYAML accept a table name or a map with name and cache
+func (r *ProviderResource) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Name = node.Value
+		r.Cache = ""
+		return nil
+	}
+	type plain ProviderResource
+	return node.Decode((*plain)(r))
+}
+
+// MarshalYAML write a resource without its own cache time as the table name
+func (r ProviderResource) MarshalYAML() (interface{}, error) {
+	if r.Cache == "" {
+		return r.Name, nil
+	}
+	type plain ProviderResource
+	return plain(r), nil
+}
+
+// ParseCache parse the cache time of a provider or a resource, such as 1d, 12h or 1d12h, empty means no cache
+func ParseCache(cache string) (time.Duration, error) {
+	cache = strings.TrimSpace(cache)
+	if cache == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(cache); err == nil {
+		return d, nil
+	}
+	invalid := fmt.Errorf("invalid cache time %s, must be like 1d, 12h or 1d12h", cache)
+	if index := strings.Index(cache, "d"); index >= 0 {
+		days, err := strconv.Atoi(cache[:index])
+		if err != nil || days < 0 {
+			return 0, invalid
+		}
+		d := time.Hour * 24 * time.Duration(days)
+		if rest := cache[index+1:]; rest != "" {
+			restDuration, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, invalid
+			}
+			d += restDuration
+		}
+		return d, nil
+	}
+	// a bare number is a count of nanoseconds as before
+	n, err := strconv.ParseInt(cache, 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return time.Duration(n), nil
+}
+
+// checkProvidersCache return an error if the cache time of a provider or of its resources is invalid
+func checkProvidersCache(providers yaml.Node) error {
+	for _, group := range providers.Content {
+		var prvd Provider
+		if err := group.Decode(&prvd); err != nil {
+			continue
+		}
+		if _, err := ParseCache(prvd.Cache); err != nil {
+			return fmt.Errorf("providers %s: %s, line %d", prvd.Name, err.Error(), group.Line)
+		}
+		for _, r := range prvd.Resources {
+			if _, err := ParseCache(r.Cache); err != nil {
+				return fmt.Errorf("providers %s resource %s: %s, line %d", prvd.Name, r.Name, err.Error(), group.Line)
+			}
+		}
+	}
+	return nil
+}
+
+// ResourceNames return the table names of the resources of provider
+func (p Provider) ResourceNames() []string {
+	var names []string
+	for _, r := range p.Resources {
+		names = append(names, r.Name)
+	}
+	return names
 }
 
 type Variable struct {
@@ -87,8 +180,91 @@ type Rule struct {
 		Remediation string   `yaml:"remediation" json:"remediation"`
 		Title       string   `yaml:"title" json:"title"`
 		Description string   `yaml:"description" json:"description"`
+		// KeyColumns are the columns identify the resource of a matched row, used to track the issue across runs
+		KeyColumns []string `yaml:"key_columns" json:"key_columns"`
+	}
+	Output string     `yaml:"output" json:"-"`
+	Tests  []RuleTest `yaml:"tests" json:"-"`
+
+	// Module is the name of the module which uses the rule, empty when the rule is not loaded by a module
+	Module string `yaml:"-" json:"-"`
+}
+
+// LocalPath return the path of the file which declares the rule, empty if the rule is downloaded
+func (r Rule) LocalPath() string {
+	if r.Path == "" || strings.Contains(r.Path, "://") {
+		return ""
+	}
+	if filepath.IsAbs(r.Path) {
+		return r.Path
+	}
+	return filepath.Join(global.WorkSpace(), r.Path)
+}
+
+// RuleTestSet is the content of a *_test.yaml beside a rule file
+type RuleTestSet struct {
+	Tests []RuleTest `yaml:"tests"`
+}
+
+// RuleTest run a rule on fixture rows and check the rows matched by the rule,
+// Rule is the name or id of the rule, only required for the tests declared in a *_test.yaml
+type RuleTest struct {
+	Rule     string                              `yaml:"rule"`
+	Name     string                              `yaml:"name"`
+	Fixtures map[string][]map[string]interface{} `yaml:"fixtures"`
+	Expect   RuleTestExpect                      `yaml:"expect"`
+}
+
+// RuleTestExpect is the expected result of a rule test, Rows only need to contain the columns to check
+type RuleTestExpect struct {
+	Count *int                     `yaml:"count"`
+	Rows  []map[string]interface{} `yaml:"rows"`
+}
+
+type SuppressionSet struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// Suppression silence the issues of a rule, when Match is set only the issues whose labels or columns
+// equal all the values in Match are silenced
+type Suppression struct {
+	Path    string            `yaml:"path" json:"path"`
+	RuleId  string            `yaml:"rule_id" json:"rule_id"`
+	Match   map[string]string `yaml:"match" json:"match"`
+	Reason  string            `yaml:"reason" json:"reason"`
+	Expires string            `yaml:"expires" json:"expires"`
+}
+
+// suppressionExpiresLayouts is the layouts accepted by Suppression.Expires
+var suppressionExpiresLayouts = []string{"2006-01-02", time.RFC3339}
+
+// ExpiresTime return the time when the suppression expires, zero time if it never expires
+func (s *Suppression) ExpiresTime() (time.Time, error) {
+	if s.Expires == "" {
+		return time.Time{}, nil
 	}
-	Output string `yaml:"output" json:"-"`
+	var err error
+	for _, layout := range suppressionExpiresLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, s.Expires, time.Local)
+		if err == nil {
+			if layout == "2006-01-02" {
+				// a date expires at the end of the day
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expires %s, must be a date like 2006-01-02 or a RFC3339 time", s.Expires)
+}
+
+// Expired check if the suppression is expired at now
+func (s *Suppression) Expired(now time.Time) bool {
+	t, err := s.ExpiresTime()
+	if err != nil {
+		return true
+	}
+	return !t.IsZero() && !now.Before(t)
 }
 
 type ModuleConfig struct {
@@ -116,7 +292,21 @@ type SelefraConfig struct {
 	CliVersion    string          `yaml:"cli_version" mapstructure:"cli_version"`
 	LogLevel      string          `yaml:"log_level" mapstructure:"log_level"`
 	ProviderDecls []*ProviderDecl `yaml:"providers" mapstructure:"providers"`
-	//Connection *DB                 `yaml:"connection" mapstructure:"connection"`
+	Connection    *DB             `yaml:"connection,omitempty" mapstructure:"connection"`
+	// Registry is the base url of the registry to download providers from, a http url, a file url or a local directory
+	Registry string `yaml:"registry,omitempty" mapstructure:"registry"`
+	// Verify is how the providers downloaded are verified
+	Verify Verify `yaml:"verify,omitempty" mapstructure:"verify"`
+}
+
+// Verify configure the verification of the providers downloaded, the sha256 of an archive is checked against
+// the checksum in the registry unless Skip
+type Verify struct {
+	// PublicKey is a minisign public key or a cosign PEM public key, the content or the path of its file,
+	// when it is set the archives must have a valid detached signature
+	PublicKey string `yaml:"public_key,omitempty" mapstructure:"public_key"`
+	// Skip disable the verification, such as for a provider under development
+	Skip bool `yaml:"skip,omitempty" mapstructure:"skip"`
 }
 
 // SelefraConfigInit is a subset for SelefraConfig without cloud config
@@ -151,6 +341,8 @@ type ProviderDeclInit struct {
 
 type DB struct {
 	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"`
+	// DSN is a raw connection string, e.g. "host=localhost user=postgres password=pass port=5432 dbname=postgres sslmode=disable"
+	DSN string `yaml:"dsn,omitempty" json:"dsn,omitempty"`
 	// These params are mutually exclusive with DSN
 	Type     string   `yaml:"type,omitempty" json:"type,omitempty"`
 	Username string   `yaml:"username,omitempty" json:"username,omitempty"`
@@ -162,6 +354,36 @@ type DB struct {
 	Extras   []string `yaml:"extras,omitempty" json:"extras,omitempty"`
 }
 
+// ConnectionString return the postgresql dsn described by db, DSN is preferred if it is set.
+// the values are quoted, so they may contain spaces, quotes and backslashes, Extras are added as they are
+func (db *DB) ConnectionString() string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+	var params []string
+	for _, param := range [][2]string{
+		{"host", db.Host},
+		{"user", db.Username},
+		{"password", db.Password},
+		{"port", db.Port},
+		{"dbname", db.Database},
+		{"sslmode", db.SSLMode},
+	} {
+		if param[1] != "" {
+			params = append(params, param[0]+"="+quoteConnValue(param[1]))
+		}
+	}
+	params = append(params, db.Extras...)
+	return strings.Join(params, " ")
+}
+
+// quoteConnValue quote a value of a keyword/value connection string like libpq, backslashes and single quotes are escaped
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
 type YamlKey int
 
 type section2File2Content map[sectionName]map[string]string
@@ -196,6 +418,9 @@ func getConfig() (c *RootConfig, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkProvidersCache(c.Providers); err != nil {
+		return nil, err
+	}
 	global.SetLogLevel(c.Selefra.LogLevel)
 	global.SetProjectName(c.Selefra.Name)
 
@@ -792,11 +1017,59 @@ func GetRules() (RuleSet, error) {
 	return rules, err
 }
 
+// RuleTestPath return the path of the *_test.yaml beside the rule file
+func RuleTestPath(rulePath string) string {
+	ext := filepath.Ext(rulePath)
+	return strings.TrimSuffix(rulePath, ext) + "_test" + ext
+}
+
+// GetRuleTests return the tests declared in the *_test.yaml beside the rule file, nil if the file not exists
+func GetRuleTests(rulePath string) ([]RuleTest, error) {
+	b, err := os.ReadFile(RuleTestPath(rulePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var testSet RuleTestSet
+	if err := yaml.Unmarshal(b, &testSet); err != nil {
+		return nil, err
+	}
+	return testSet.Tests, nil
+}
+
+// GetSuppressions return all suppressions declared in workspace
+func GetSuppressions() ([]Suppression, error) {
+	var suppressions []Suppression
+	configMap, err := readAllConfig(global.WorkSpace())
+	if err != nil {
+		return nil, err
+	}
+	for suppressionPath, suppression := range configMap[SUPPRESSIONS] {
+		var baseSuppression SuppressionSet
+		err := yaml.Unmarshal([]byte(suppression), &baseSuppression)
+		if err != nil {
+			return nil, err
+		}
+		for i := range baseSuppression.Suppressions {
+			baseSuppression.Suppressions[i].Path = suppressionPath
+		}
+		suppressions = append(suppressions, baseSuppression.Suppressions...)
+	}
+	return suppressions, nil
+}
+
 func (c *RootConfig) TestConfigByNode() error {
 	configMap, err := readAllConfig(global.WorkSpace())
 	if err != nil {
 		return err
 	}
+	return checkConfigByNode(configMap)
+}
+
+// checkConfigByNode check the keys and values of all the configurations read from a workspace
+func checkConfigByNode(configMap section2File2Content) error {
 	clientMap := configMap[SELEFRA]
 
 	for pathStr, configStr := range clientMap {
@@ -806,6 +1079,8 @@ func (c *RootConfig) TestConfigByNode() error {
 		selefraMap["name"] = nil
 		selefraMap["connection"] = new(yaml.Node)
 		selefraMap["log_level"] = new(yaml.Node)
+		selefraMap["registry"] = new(yaml.Node)
+		selefraMap["verify"] = new(yaml.Node)
 		selefraMap["providers"] = nil
 		bodyNode := new(yaml.Node)
 		err := yaml.Unmarshal([]byte(configStr), bodyNode)
@@ -817,6 +1092,35 @@ func (c *RootConfig) TestConfigByNode() error {
 			return err
 		}
 
+		if len(selefraMap["connection"].Content) > 0 {
+			var connectionMap = make(map[string]*yaml.Node)
+			connectionMap["driver"] = new(yaml.Node)
+			connectionMap["dsn"] = new(yaml.Node)
+			connectionMap["type"] = new(yaml.Node)
+			connectionMap["username"] = new(yaml.Node)
+			connectionMap["password"] = new(yaml.Node)
+			connectionMap["host"] = new(yaml.Node)
+			connectionMap["port"] = new(yaml.Node)
+			connectionMap["database"] = new(yaml.Node)
+			connectionMap["sslmode"] = new(yaml.Node)
+			connectionMap["extras"] = new(yaml.Node)
+			err = checkNode(connectionMap, selefraMap["connection"].Content, pathStr, "selefra.connection:")
+			if err != nil {
+				return err
+			}
+			if connectionMap["dsn"].Value != "" {
+				for _, key := range []string{"username", "password", "host", "port", "database", "sslmode", "extras"} {
+					if connectionMap[key].Kind != 0 {
+						errStr := fmt.Sprintf("%s selefra.connection: dsn is mutually exclusive with %s,Occurrence location %d:%d", pathStr, key, connectionMap["dsn"].Line, connectionMap["dsn"].Column)
+						return errors.New(errStr)
+					}
+				}
+			} else if connectionMap["host"].Value == "" {
+				errStr := fmt.Sprintf("%s selefra.connection: Missing configuration host or dsn", pathStr)
+				return errors.New(errStr)
+			}
+		}
+
 		for index, node := range selefraMap["providers"].Content {
 			var providersMap = make(map[string]*yaml.Node)
 			providersMap["name"] = nil
@@ -869,6 +1173,7 @@ func (c *RootConfig) TestConfigByNode() error {
 			ruleMap["interval"] = new(yaml.Node)
 			ruleMap["metadata"] = nil
 			ruleMap["output"] = nil
+			ruleMap["tests"] = new(yaml.Node)
 			yamlPath := fmt.Sprintf("rules[%d]", index)
 			err = checkNode(ruleMap, node.Content, pathStr, yamlPath+":")
 
@@ -900,6 +1205,7 @@ func (c *RootConfig) TestConfigByNode() error {
 					ruleMetadataMap["title"] = nil
 					ruleMetadataMap["author"] = nil
 					ruleMetadataMap["description"] = nil
+					ruleMetadataMap["key_columns"] = new(yaml.Node)
 					err = checkNode(ruleMetadataMap, ruleMap["metadata"].Content, pathStr, yamlPath+"metadata:")
 					if err != nil {
 						return err
@@ -910,6 +1216,36 @@ func (c *RootConfig) TestConfigByNode() error {
 		}
 	}
 
+	suppressionsMap := configMap[SUPPRESSIONS]
+	for pathStr, suppressionsStr := range suppressionsMap {
+		var suppressionsNode = new(yaml.Node)
+		err := yaml.Unmarshal([]byte(suppressionsStr), suppressionsNode)
+		if err != nil {
+			return err
+		}
+		for index, node := range suppressionsNode.Content[0].Content[1].Content {
+			var suppressionMap = make(map[string]*yaml.Node)
+			suppressionMap["rule_id"] = nil
+			suppressionMap["match"] = new(yaml.Node)
+			suppressionMap["reason"] = nil
+			suppressionMap["expires"] = new(yaml.Node)
+			yamlPath := fmt.Sprintf("suppressions[%d]:", index)
+			err = checkNode(suppressionMap, node.Content, pathStr, yamlPath)
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(suppressionMap["reason"].Value) == "" {
+				errStr := fmt.Sprintf("%s %s reason can not be empty,Occurrence location %d:%d", pathStr, yamlPath, suppressionMap["reason"].Line, suppressionMap["reason"].Column)
+				return errors.New(errStr)
+			}
+			suppression := Suppression{Expires: suppressionMap["expires"].Value}
+			if _, err := suppression.ExpiresTime(); err != nil {
+				errStr := fmt.Sprintf("%s %s %s,Occurrence location %d:%d", pathStr, yamlPath, err.Error(), suppressionMap["expires"].Line, suppressionMap["expires"].Column)
+				return errors.New(errStr)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,45 @@
+package global
+
+import "errors"
+
+const (
+	// ExitCodeError is the exit code when selefra failed to execute, e.g. config, storage or provider error
+	ExitCodeError = 1
+
+	// ExitCodeIssuesFound is the exit code when apply found issues at or above the --fail-on severity
+	ExitCodeIssuesFound = 2
+)
+
+// ExitError is an error carrying the code that the process should exit with
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// NewExitError wrap err with an exit code
+func NewExitError(code int, err error) error {
+	return &ExitError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+// ExitCode return the exit code for err, 0 when err is nil and ExitCodeError when err does not carry a code
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitCodeError
+}
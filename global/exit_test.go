@@ -0,0 +1,18 @@
+package global
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ExitCode(t *testing.T) {
+	require.Equal(t, 0, ExitCode(nil))
+
+	require.Equal(t, ExitCodeError, ExitCode(errors.New("config error")))
+
+	err := NewExitError(ExitCodeIssuesFound, errors.New("issues found"))
+	require.Equal(t, ExitCodeIssuesFound, ExitCode(err))
+	require.Equal(t, ExitCodeIssuesFound, ExitCode(fmt.Errorf("apply: %w", err)))
+}
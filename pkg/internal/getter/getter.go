@@ -2,8 +2,10 @@ package getter
 
 import (
 	"context"
+	"fmt"
 	"github.com/selefra/selefra/ui/progress"
 	"os"
+	"strings"
 	"time"
 
 	getter "github.com/hashicorp/go-getter"
@@ -99,3 +101,17 @@ func ModuleGet(ctx context.Context, installPath, url string, options ...getter.C
 	}
 	return nil
 }
+
+// Decompress extract the archive file src into the directory dst, the format is told by the extension of src
+func Decompress(dst, src string) error {
+	var matched string
+	for ext := range decompressors {
+		if strings.HasSuffix(src, "."+ext) && len(ext) > len(matched) {
+			matched = ext
+		}
+	}
+	if matched == "" {
+		return fmt.Errorf("unsupported archive %s", src)
+	}
+	return decompressors[matched].Decompress(dst, src, true, 0)
+}
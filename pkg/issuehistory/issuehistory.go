@@ -0,0 +1,301 @@
+package issuehistory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/selefra/selefra-provider-sdk/storage"
+	"github.com/selefra/selefra-utils/pkg/id_util"
+	"github.com/selefra/selefra/pkg/report"
+	"github.com/selefra/selefra/pkg/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// IssuesTable keep the latest state of every issue ever found, one row per fingerprint
+	IssuesTable = "selefra_issues"
+	// IssueRunsTable record which issues were seen by every apply run
+	IssueRunsTable = "selefra_issue_runs"
+	// ApplyRunsTable record every apply run whose issues were recorded, including the runs which found no issue
+	ApplyRunsTable = "selefra_apply_runs"
+)
+
+// defaultKeyColumns are used to identify the resource of a row when the rule does not declare key_columns
+var defaultKeyColumns = []string{"arn", "id"}
+
+const createIssuesTableSql = `CREATE TABLE IF NOT EXISTS selefra_issues (
+	fingerprint text PRIMARY KEY,
+	rule_id text,
+	rule_name text,
+	schema_name text,
+	severity text,
+	provider text,
+	title text,
+	output text,
+	labels jsonb,
+	row_data jsonb,
+	suppression text,
+	first_seen timestamptz,
+	last_seen timestamptz,
+	resolved_at timestamptz,
+	run_id text
+)`
+
+const createIssueRunsTableSql = `CREATE TABLE IF NOT EXISTS selefra_issue_runs (
+	run_id text,
+	fingerprint text,
+	seen_at timestamptz,
+	PRIMARY KEY (run_id, fingerprint)
+)`
+
+const createApplyRunsTableSql = `CREATE TABLE IF NOT EXISTS selefra_apply_runs (
+	run_id text PRIMARY KEY,
+	recorded_at timestamptz
+)`
+
+// recordSql record a run, upsert the issues it has seen and record them in its runs, then resolve the open issues of
+// its successful evaluations which it has not seen. it is a single statement, so a run is recorded entirely or not
+// at all. the sub statements see the same snapshot, so the issues seen by the run are excluded from resolving
+const recordSql = `WITH seen AS (
+	SELECT * FROM jsonb_to_recordset($1::jsonb) AS s(
+		fingerprint text, rule_id text, rule_name text, schema_name text, severity text, provider text,
+		title text, output text, labels jsonb, row_data jsonb, suppression text
+	)
+), evaluated AS (
+	SELECT * FROM jsonb_to_recordset($2::jsonb) AS e(rule_id text, schema_name text)
+), run AS (
+	INSERT INTO selefra_apply_runs (run_id, recorded_at) VALUES ($3::text, $4::timestamptz)
+	ON CONFLICT (run_id) DO UPDATE SET recorded_at = EXCLUDED.recorded_at
+), upserted AS (
+	INSERT INTO selefra_issues (
+		fingerprint, rule_id, rule_name, schema_name, severity, provider, title, output, labels, row_data, suppression, first_seen, last_seen, resolved_at, run_id
+	) SELECT fingerprint, rule_id, rule_name, schema_name, severity, provider, title, output, labels, row_data, suppression,
+		$4::timestamptz, $4::timestamptz, NULL, $3::text FROM seen
+	ON CONFLICT (fingerprint) DO UPDATE SET
+		rule_name = EXCLUDED.rule_name,
+		severity = EXCLUDED.severity,
+		provider = EXCLUDED.provider,
+		title = EXCLUDED.title,
+		output = EXCLUDED.output,
+		labels = EXCLUDED.labels,
+		row_data = EXCLUDED.row_data,
+		suppression = EXCLUDED.suppression,
+		last_seen = EXCLUDED.last_seen,
+		resolved_at = NULL,
+		run_id = EXCLUDED.run_id
+	RETURNING fingerprint
+), seen_runs AS (
+	INSERT INTO selefra_issue_runs (run_id, fingerprint, seen_at) SELECT $3::text, fingerprint, $4::timestamptz FROM upserted
+	ON CONFLICT DO NOTHING
+)
+UPDATE selefra_issues SET resolved_at = $4::timestamptz
+	WHERE resolved_at IS NULL AND run_id <> $3::text
+	AND (rule_id, schema_name) IN (SELECT rule_id, schema_name FROM evaluated)
+	AND fingerprint NOT IN (SELECT fingerprint FROM seen)`
+
+// KeyColumns return the columns which identify the resource of a matched row,
+// declared columns take precedence, then arn or id, all the columns are used if the row has none of them
+func KeyColumns(declared []string, row map[string]interface{}) []string {
+	if len(declared) > 0 {
+		return declared
+	}
+	for _, column := range defaultKeyColumns {
+		if _, ok := row[column]; ok {
+			return []string{column}
+		}
+	}
+	var columns []string
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// Fingerprint build a stable identity for an issue from the rule, schema and key columns of the matched row
+func Fingerprint(ruleKey, schema string, keyColumns []string, row map[string]interface{}) string {
+	columns := append([]string{}, keyColumns...)
+	sort.Strings(columns)
+	var b strings.Builder
+	b.WriteString(ruleKey)
+	b.WriteByte(0)
+	b.WriteString(schema)
+	for _, column := range columns {
+		b.WriteByte(0)
+		b.WriteString(column)
+		b.WriteByte('=')
+		b.WriteString(utils.Strava(row[column]))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Recorder write the issues of an apply run to the issue history tables
+type Recorder struct {
+	storage storage.Storage
+	runId   string
+}
+
+// NewRecorder create the issue history tables if not exists and return a recorder with a new run id,
+// the recorder owns storage and closes it in Close
+func NewRecorder(ctx context.Context, storage storage.Storage) (*Recorder, error) {
+	if diag := storage.Exec(ctx, createIssuesTableSql); diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	if diag := storage.Exec(ctx, createIssueRunsTableSql); diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	if diag := storage.Exec(ctx, createApplyRunsTableSql); diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	return &Recorder{
+		storage: storage,
+		runId:   id_util.RandomId(),
+	}, nil
+}
+
+// RunId return the id of the apply run
+func (r *Recorder) RunId() string {
+	return r.runId
+}
+
+// Close close the storage of the recorder
+func (r *Recorder) Close() {
+	r.storage.Close()
+}
+
+// issueRecord is an issue seen by a run as an input row of recordSql
+type issueRecord struct {
+	Fingerprint string                 `json:"fingerprint"`
+	RuleId      string                 `json:"rule_id"`
+	RuleName    string                 `json:"rule_name"`
+	Schema      string                 `json:"schema_name"`
+	Severity    string                 `json:"severity"`
+	Provider    string                 `json:"provider"`
+	Title       string                 `json:"title"`
+	Output      string                 `json:"output"`
+	Labels      map[string]string      `json:"labels"`
+	Row         map[string]interface{} `json:"row_data"`
+	Suppression string                 `json:"suppression"`
+}
+
+// evaluationRecord is a successful evaluation of a run as an input row of recordSql
+type evaluationRecord struct {
+	RuleId string `json:"rule_id"`
+	Schema string `json:"schema_name"`
+}
+
+// Record save the run with the issues and suppressed issues of the report as seen at now, then mark the open issues
+// of every successful evaluation which were not seen in this run as resolved, all in a single transaction
+func (r *Recorder) Record(ctx context.Context, rep *report.Report, now time.Time) error {
+	issues, evaluations := recordInput(rep)
+	issuesJson, err := json.Marshal(issues)
+	if err != nil {
+		return err
+	}
+	evaluationsJson, err := json.Marshal(evaluations)
+	if err != nil {
+		return err
+	}
+	if diag := r.storage.Exec(ctx, recordSql, string(issuesJson), string(evaluationsJson), r.runId, now); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
+
+// recordInput return the issues of rep with a fingerprint and its successful evaluations, an issue found twice
+// is recorded once as the last one because a statement can not upsert a row twice
+func recordInput(rep *report.Report) ([]issueRecord, []evaluationRecord) {
+	var issues = make([]issueRecord, 0)
+	var index = make(map[string]int)
+	for _, list := range [][]*report.Issue{rep.Issues, rep.Suppressed} {
+		for _, issue := range list {
+			if issue.Fingerprint == "" {
+				continue
+			}
+			record := issueRecord{
+				Fingerprint: issue.Fingerprint,
+				RuleId:      issue.Rule.Key(),
+				RuleName:    issue.Rule.Name,
+				Schema:      issue.Schema,
+				Severity:    issue.Rule.Severity,
+				Provider:    issue.Rule.Provider,
+				Title:       issue.Rule.Title,
+				Output:      issue.Output,
+				Labels:      issue.Labels,
+				Row:         issue.Row,
+				Suppression: issue.Suppression,
+			}
+			if i, ok := index[issue.Fingerprint]; ok {
+				issues[i] = record
+				continue
+			}
+			index[issue.Fingerprint] = len(issues)
+			issues = append(issues, record)
+		}
+	}
+	var evaluations = make([]evaluationRecord, 0)
+	for _, evaluation := range rep.Evaluations {
+		if evaluation.Error != "" {
+			continue
+		}
+		evaluations = append(evaluations, evaluationRecord{RuleId: evaluation.Rule.Key(), Schema: evaluation.Schema})
+	}
+	return issues, evaluations
+}
+
+const runIssuesSql = `SELECT i.fingerprint, i.rule_id, i.rule_name, i.schema_name, i.severity, i.provider, i.title, i.output
+	FROM selefra_issue_runs r JOIN selefra_issues i ON i.fingerprint = r.fingerprint
+	WHERE r.run_id = $1`
+
+const runRecordedSql = `SELECT count(*) FROM selefra_apply_runs WHERE run_id = $1`
+
+// ErrRunNotFound is returned when a run was not recorded
+var ErrRunNotFound = errors.New("the run was not recorded")
+
+// RunIssues return the issues seen by the apply run of runId, it is empty for a recorded run which found no issue
+func RunIssues(ctx context.Context, storage storage.Storage, runId string) ([]*report.Issue, error) {
+	res, diag := storage.Query(ctx, runIssuesSql, runId)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	table, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	var issues []*report.Issue
+	for _, row := range table.GetMatrix() {
+		issues = append(issues, &report.Issue{
+			Fingerprint: utils.Strava(row[0]),
+			Rule: report.Rule{
+				Id:       utils.Strava(row[1]),
+				Name:     utils.Strava(row[2]),
+				Severity: utils.Strava(row[4]),
+				Provider: utils.Strava(row[5]),
+				Title:    utils.Strava(row[6]),
+			},
+			Schema: utils.Strava(row[3]),
+			Output: utils.Strava(row[7]),
+		})
+	}
+	if len(issues) > 0 {
+		return issues, nil
+	}
+
+	res, diag = storage.Query(ctx, runRecordedSql, runId)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	table, diag = res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	if matrix := table.GetMatrix(); len(matrix) == 0 || utils.Strava(matrix[0][0]) == "0" {
+		return nil, ErrRunNotFound
+	}
+	return []*report.Issue{}, nil
+}
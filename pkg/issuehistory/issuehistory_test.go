@@ -0,0 +1,63 @@
+package issuehistory
+
+import (
+	"encoding/json"
+	"github.com/selefra/selefra/pkg/report"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKeyColumns(t *testing.T) {
+	row := map[string]interface{}{"id": "vol-1", "encrypted": false, "arn": "arn:aws:ec2:vol-1"}
+	require.Equal(t, []string{"region"}, KeyColumns([]string{"region"}, row))
+	require.Equal(t, []string{"arn"}, KeyColumns(nil, row))
+	require.Equal(t, []string{"id"}, KeyColumns(nil, map[string]interface{}{"id": "vol-1"}))
+	require.Equal(t, []string{"encrypted", "name"}, KeyColumns(nil, map[string]interface{}{"name": "b", "encrypted": false}))
+}
+
+func TestFingerprint(t *testing.T) {
+	row := map[string]interface{}{"id": "vol-1", "size": 10}
+	fp := Fingerprint("SF010302", "aws_001", []string{"id"}, row)
+	require.Len(t, fp, 64)
+
+	changed := map[string]interface{}{"id": "vol-1", "size": 20}
+	require.Equal(t, fp, Fingerprint("SF010302", "aws_001", []string{"id"}, changed))
+	require.NotEqual(t, fp, Fingerprint("SF010302", "aws_002", []string{"id"}, row))
+	require.NotEqual(t, fp, Fingerprint("SF010303", "aws_001", []string{"id"}, row))
+	require.NotEqual(t, fp, Fingerprint("SF010302", "aws_001", []string{"id"}, map[string]interface{}{"id": "vol-2"}))
+}
+
+func TestRecordInput(t *testing.T) {
+	rule := report.Rule{Id: "SF010302", Name: "ebs_encrypted", Severity: "High", Provider: "aws", Title: "EBS not encrypted"}
+	rep := &report.Report{
+		Evaluations: []*report.Evaluation{
+			{Rule: rule, Schema: "aws_001"},
+			{Rule: report.Rule{Name: "broken"}, Schema: "aws_001", Error: "syntax error"},
+			{Rule: report.Rule{Name: "clean"}, Schema: "aws_002"},
+		},
+		Issues: []*report.Issue{
+			{Fingerprint: "fp1", Rule: rule, Schema: "aws_001", Output: "vol-1", Row: map[string]interface{}{"id": "vol-1"}},
+			{Rule: rule, Schema: "aws_001", Output: "no fingerprint"},
+		},
+		Suppressed: []*report.Issue{
+			{Fingerprint: "fp1", Rule: rule, Schema: "aws_001", Output: "vol-1", Suppression: "accepted"},
+			{Fingerprint: "fp2", Rule: rule, Schema: "aws_001", Output: "vol-2", Suppression: "accepted"},
+		},
+	}
+	issues, evaluations := recordInput(rep)
+	require.Len(t, issues, 2)
+	require.Equal(t, "fp1", issues[0].Fingerprint)
+	require.Equal(t, "accepted", issues[0].Suppression)
+	require.Equal(t, "SF010302", issues[0].RuleId)
+	require.Equal(t, "fp2", issues[1].Fingerprint)
+	require.Equal(t, []evaluationRecord{{RuleId: "SF010302", Schema: "aws_001"}, {RuleId: "clean", Schema: "aws_002"}}, evaluations)
+
+	// a clean run is still recorded, the inputs are empty arrays rather than null
+	issues, evaluations = recordInput(&report.Report{})
+	b, err := json.Marshal(issues)
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(b))
+	b, err = json.Marshal(evaluations)
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(b))
+}
@@ -0,0 +1,240 @@
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the lock file in a workspace
+const FileName = "selefra.lock.yaml"
+
+const header = "# This file is maintained by selefra, it locks the versions of the providers and modules of the project.\n" +
+	"# Commit it to share the versions with your team, run `selefra lock upgrade` to upgrade them.\n"
+
+// LockFile is the versions of the providers and modules resolved in a workspace, it is honored by every run
+// so everyone using the workspace uses the same versions
+type LockFile struct {
+	Providers []*Provider `yaml:"providers,omitempty"`
+	Modules   []*Module   `yaml:"modules,omitempty"`
+
+	path    string
+	changed bool
+}
+
+// Provider is a provider locked
+type Provider struct {
+	Name   string `yaml:"name"`
+	Source string `yaml:"source,omitempty"`
+	// Constraint is the version declared in config, the provider is resolved again once it is changed
+	Constraint string `yaml:"constraint"`
+	Version    string `yaml:"version"`
+	// Checksums is the sha256 of the archive of the provider by platform such as linux_amd64
+	Checksums map[string]string `yaml:"checksums,omitempty"`
+}
+
+// Module is a module downloaded from a registry locked
+type Module struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+	// Hash is the hash of the files of the module, see HashDir
+	Hash string `yaml:"hash"`
+}
+
+// Path return the path of the lock file of workspace
+func Path(workspace string) string {
+	return filepath.Join(workspace, FileName)
+}
+
+// Load read the lock file of workspace, it is empty if the workspace has no lock file
+func Load(workspace string) (*LockFile, error) {
+	lock := &LockFile{path: Path(workspace)}
+	b, err := os.ReadFile(lock.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, lock); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", FileName, err.Error())
+	}
+	return lock, nil
+}
+
+var (
+	sharedLock sync.Mutex
+	shared     = make(map[string]*LockFile)
+)
+
+// Shared return the lock file of workspace loaded once in a run, the config loading which locks modules and
+// the commands which lock providers share it, so a save of one does not overwrite what another locked
+func Shared(workspace string) (*LockFile, error) {
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+	if lock, ok := shared[Path(workspace)]; ok {
+		return lock, nil
+	}
+	lock, err := Load(workspace)
+	if err != nil {
+		return nil, err
+	}
+	shared[lock.path] = lock
+	return lock, nil
+}
+
+// Save write the lock file if it is changed since loaded
+func (l *LockFile) Save() error {
+	if !l.changed {
+		return nil
+	}
+	sort.Slice(l.Providers, func(i, j int) bool {
+		return l.Providers[i].Name < l.Providers[j].Name
+	})
+	sort.Slice(l.Modules, func(i, j int) bool {
+		return l.Modules[i].Name < l.Modules[j].Name
+	})
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path, append([]byte(header), b...), 0644); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
+
+// Provider return the provider name locked, nil if it is not locked
+func (l *LockFile) Provider(name string) *Provider {
+	for _, p := range l.Providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetProvider lock provider, it replaces the provider with the same name
+func (l *LockFile) SetProvider(provider *Provider) {
+	for i, p := range l.Providers {
+		if p.Name == provider.Name {
+			if p.equal(provider) {
+				return
+			}
+			l.Providers[i] = provider
+			l.changed = true
+			return
+		}
+	}
+	l.Providers = append(l.Providers, provider)
+	l.changed = true
+}
+
+// Module return the module name locked, nil if it is not locked
+func (l *LockFile) Module(name string) *Module {
+	for _, m := range l.Modules {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// SetModule lock module, it replaces the module with the same name
+func (l *LockFile) SetModule(module *Module) {
+	for i, m := range l.Modules {
+		if m.Name == module.Name {
+			if *m == *module {
+				return
+			}
+			l.Modules[i] = module
+			l.changed = true
+			return
+		}
+	}
+	l.Modules = append(l.Modules, module)
+	l.changed = true
+}
+
+// Unlock remove the providers and modules named, all of them are removed if no name is given
+func (l *LockFile) Unlock(names ...string) {
+	var unlocked = make(map[string]bool)
+	for _, name := range names {
+		unlocked[name] = true
+	}
+	var providers []*Provider
+	for _, p := range l.Providers {
+		if len(names) > 0 && !unlocked[p.Name] {
+			providers = append(providers, p)
+		}
+	}
+	var modules []*Module
+	for _, m := range l.Modules {
+		if len(names) > 0 && !unlocked[m.Name] {
+			modules = append(modules, m)
+		}
+	}
+	if len(providers) != len(l.Providers) || len(modules) != len(l.Modules) {
+		l.changed = true
+	}
+	l.Providers, l.Modules = providers, modules
+}
+
+func (p *Provider) equal(other *Provider) bool {
+	if p.Name != other.Name || p.Source != other.Source || p.Constraint != other.Constraint || p.Version != other.Version ||
+		len(p.Checksums) != len(other.Checksums) {
+		return false
+	}
+	for platform, checksum := range p.Checksums {
+		if other.Checksums[platform] != checksum {
+			return false
+		}
+	}
+	return true
+}
+
+// HashDir return the hash of the files in dir, it is the sha256 of the sorted list of the sha256 and the
+// relative path of every file, so it changes if any file is added, removed, renamed or modified
+func HashDir(dir string) (string, error) {
+	var lines []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		lines = append(lines, fmt.Sprintf("%x  %s\n", h.Sum(nil), filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "")))
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
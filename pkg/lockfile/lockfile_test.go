@@ -0,0 +1,94 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFile(t *testing.T) {
+	workspace := t.TempDir()
+	lock, err := Load(workspace)
+	require.NoError(t, err)
+	require.Nil(t, lock.Provider("aws"))
+
+	// nothing is written until something is locked
+	require.NoError(t, lock.Save())
+	_, err = os.Stat(Path(workspace))
+	require.True(t, os.IsNotExist(err))
+
+	lock.SetProvider(&Provider{Name: "gcp", Constraint: "latest", Version: "v0.0.3"})
+	lock.SetProvider(&Provider{Name: "aws", Source: "selefra/aws", Constraint: "~> 0.0.9", Version: "v0.0.12",
+		Checksums: map[string]string{"linux_amd64": "abc"}})
+	lock.SetModule(&Module{Name: "selefra/aws_compliance", Version: "v0.0.1", Hash: "sha256:123"})
+	require.NoError(t, lock.Save())
+
+	loaded, err := Load(workspace)
+	require.NoError(t, err)
+	require.Equal(t, []string{"aws", "gcp"}, []string{loaded.Providers[0].Name, loaded.Providers[1].Name})
+	require.Equal(t, "v0.0.12", loaded.Provider("aws").Version)
+	require.Equal(t, "abc", loaded.Provider("aws").Checksums["linux_amd64"])
+	require.Equal(t, "sha256:123", loaded.Module("selefra/aws_compliance").Hash)
+
+	loaded.SetProvider(&Provider{Name: "gcp", Constraint: "latest", Version: "v0.0.3"})
+	require.False(t, loaded.changed)
+	loaded.SetProvider(&Provider{Name: "gcp", Constraint: "latest", Version: "v0.0.4"})
+	require.True(t, loaded.changed)
+	require.Len(t, loaded.Providers, 2)
+
+	loaded.Unlock("aws", "selefra/aws_compliance")
+	require.Nil(t, loaded.Provider("aws"))
+	require.Nil(t, loaded.Module("selefra/aws_compliance"))
+	require.NotNil(t, loaded.Provider("gcp"))
+	loaded.Unlock()
+	require.Empty(t, loaded.Providers)
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rules"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "module.yaml"), []byte("modules: []"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules", "rule.yaml"), []byte("rules: []"), 0644))
+
+	hash, err := HashDir(dir)
+	require.NoError(t, err)
+	again, err := HashDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, hash, again)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules", "rule.yaml"), []byte("rules: [1]"), 0644))
+	changed, err := HashDir(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changed)
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "rules", "rule.yaml"), filepath.Join(dir, "rules", "renamed.yaml")))
+	renamed, err := HashDir(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, changed, renamed)
+}
+
+func TestShared(t *testing.T) {
+	workspace := t.TempDir()
+	lock, err := Shared(workspace)
+	require.NoError(t, err)
+	again, err := Shared(workspace)
+	require.NoError(t, err)
+	require.Same(t, lock, again)
+
+	// a module locked while loading config is kept by the save of the providers
+	lock.SetModule(&Module{Name: "selefra/aws_compliance", Version: "v0.0.1", Hash: "sha256:123"})
+	require.NoError(t, lock.Save())
+	again.SetProvider(&Provider{Name: "aws", Constraint: "latest", Version: "v0.0.3"})
+	require.NoError(t, again.Save())
+
+	loaded, err := Load(workspace)
+	require.NoError(t, err)
+	require.NotNil(t, loaded.Module("selefra/aws_compliance"))
+	require.NotNil(t, loaded.Provider("aws"))
+
+	other, err := Shared(t.TempDir())
+	require.NoError(t, err)
+	require.NotSame(t, lock, other)
+}
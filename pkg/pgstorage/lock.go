@@ -0,0 +1,257 @@
+package pgstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
+	"github.com/selefra/selefra/pkg/utils"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// lockKeyPrefix is the prefix of the keys in selefra_meta_kv which the storage keeps its locks in
+	lockKeyPrefix = "storage_lock_id_"
+	// lockHolderKeyPrefix is the prefix of the keys in selefra_meta_kv which keep the holders of the locks
+	lockHolderKeyPrefix = "lock_holder:"
+)
+
+// ErrLockTimeout is returned by AcquireLock when the lock is not released within the timeout
+var ErrLockTimeout = errors.New("lock timeout")
+
+// lockRetryInterval is how long to wait before trying to acquire a held lock again
+var lockRetryInterval = 5 * time.Second
+
+// LockHolder is the process which holds a lock
+type LockHolder struct {
+	Owner      string    `json:"owner"`
+	Host       string    `json:"host"`
+	Pid        int       `json:"pid"`
+	Command    string    `json:"command"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// NewLockHolder return the holder of the current process for owner
+func NewLockHolder(owner string) *LockHolder {
+	host, _ := os.Hostname()
+	return &LockHolder{
+		Owner:   owner,
+		Host:    host,
+		Pid:     os.Getpid(),
+		Command: strings.Join(os.Args, " "),
+	}
+}
+
+// Stale return true if the holder is a process of this host which is no longer running,
+// it crashed or was killed without releasing the lock
+func (h *LockHolder) Stale() bool {
+	host, err := os.Hostname()
+	if err != nil || host != h.Host || h.Pid <= 0 {
+		return false
+	}
+	return !processRunning(h.Pid)
+}
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// FindProcess fails on windows if the process does not exist
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// LockInfo is a lock held in a schema
+type LockInfo struct {
+	Id        string
+	Owner     string
+	Count     int
+	ExpiresAt time.Time
+	// Holder is nil if the lock is held by a version which does not record its holder
+	Holder *LockHolder
+
+	value       string
+	holderValue string
+}
+
+// Expired return true if the lease of the lock expired at now, the holder stopped refreshing it
+// and the lock is broken by the next one trying to acquire it
+func (l *LockInfo) Expired(now time.Time) bool {
+	return l.ExpiresAt.Before(now)
+}
+
+// String describe the holder of the lock
+func (l *LockInfo) String() string {
+	if l.Holder == nil {
+		return fmt.Sprintf("by %s until %s", l.Owner, l.ExpiresAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("by %s (pid %d on %s, acquired at %s) until %s", l.Holder.Command, l.Holder.Pid, l.Holder.Host,
+		l.Holder.AcquiredAt.Format(time.RFC3339), l.ExpiresAt.Format(time.RFC3339))
+}
+
+// AcquireLock acquire the lock id for holder, it waits until the lock is released, its lease expires or timeout,
+// zero timeout waits without limit. a lock whose holder is stale is broken at once
+func AcquireLock(ctx context.Context, sto *postgresql_storage.PostgresqlStorage, id string, holder *LockHolder, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := sto.Lock(ctx, id, holder.Owner); err == nil {
+			holder.AcquiredAt = time.Now()
+			b, err := json.Marshal(holder)
+			if err != nil {
+				return err
+			}
+			return SetStorageValue(ctx, sto, lockHolderKey(id), string(b))
+		}
+
+		info, err := readLock(ctx, sto, id)
+		if err != nil {
+			return err
+		}
+		if info != nil && info.Holder != nil && info.Holder.Stale() {
+			if err := breakLock(ctx, sto, info); err != nil {
+				return err
+			}
+			continue
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			if info == nil {
+				return fmt.Errorf("%w: %s", ErrLockTimeout, id)
+			}
+			return fmt.Errorf("%w: %s is held %s", ErrLockTimeout, id, info)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// ReleaseLock release the lock id held by owner
+func ReleaseLock(ctx context.Context, sto *postgresql_storage.PostgresqlStorage, id, owner string) error {
+	if err := sto.UnLock(ctx, id, owner); err != nil {
+		return err
+	}
+	info, err := readLock(ctx, sto, id)
+	if err != nil {
+		return err
+	}
+	// a reentrant lock is still held
+	if info != nil && info.Owner == owner {
+		return nil
+	}
+	if diag := sto.Exec(ctx, `DELETE FROM selefra_meta_kv WHERE "key" = $1 AND value::jsonb->>'owner' = $2`, lockHolderKey(id), owner); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
+
+// ListLocks return the locks held in the schema of sto
+func ListLocks(ctx context.Context, sto *postgresql_storage.PostgresqlStorage) ([]*LockInfo, error) {
+	rows, diag := sto.ListKey(ctx)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	var values = make(map[string]string)
+	for _, row := range rows.GetMatrix() {
+		values[utils.Strava(row[0])] = utils.Strava(row[1])
+	}
+	var locks []*LockInfo
+	for key, value := range values {
+		if !strings.HasPrefix(key, lockKeyPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(key, lockKeyPrefix)
+		info, err := parseLock(id, value, values[lockHolderKey(id)])
+		if err != nil {
+			continue
+		}
+		locks = append(locks, info)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Id < locks[j].Id
+	})
+	return locks, nil
+}
+
+// BreakLock release the lock id whoever holds it, it returns false if the lock is not held
+func BreakLock(ctx context.Context, sto *postgresql_storage.PostgresqlStorage, id string) (bool, error) {
+	info, err := readLock(ctx, sto, id)
+	if err != nil || info == nil {
+		return false, err
+	}
+	return true, breakLock(ctx, sto, info)
+}
+
+// breakLock delete the lock and its holder if they are not changed since read
+func breakLock(ctx context.Context, sto *postgresql_storage.PostgresqlStorage, info *LockInfo) error {
+	if diag := sto.Exec(ctx, `DELETE FROM selefra_meta_kv WHERE "key" = $1 AND value = $2`, lockKeyPrefix+info.Id, info.value); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	if info.holderValue == "" {
+		return nil
+	}
+	if diag := sto.Exec(ctx, `DELETE FROM selefra_meta_kv WHERE "key" = $1 AND value = $2`, lockHolderKey(info.Id), info.holderValue); diag != nil && diag.HasError() {
+		return errors.New(diag.ToString())
+	}
+	return nil
+}
+
+// readLock return the lock id, nil if it is not held
+func readLock(ctx context.Context, sto *postgresql_storage.PostgresqlStorage, id string) (*LockInfo, error) {
+	res, diag := sto.Query(ctx, `SELECT "key", value FROM selefra_meta_kv WHERE "key" = $1 OR "key" = $2`, lockKeyPrefix+id, lockHolderKey(id))
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	var value, holderValue string
+	for _, row := range rows.GetMatrix() {
+		if utils.Strava(row[0]) == lockKeyPrefix+id {
+			value = utils.Strava(row[1])
+		} else {
+			holderValue = utils.Strava(row[1])
+		}
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return parseLock(id, value, holderValue)
+}
+
+func parseLock(id, value, holderValue string) (*LockInfo, error) {
+	information, err := postgresql_storage.FromJsonString(value)
+	if err != nil {
+		return nil, err
+	}
+	info := &LockInfo{
+		Id:        id,
+		Owner:     information.OwnerId,
+		Count:     information.LockCount,
+		ExpiresAt: information.ExceptedExpireTime,
+		value:     value,
+	}
+	var holder LockHolder
+	// the holder left by a former owner is ignored
+	if holderValue != "" && json.Unmarshal([]byte(holderValue), &holder) == nil && holder.Owner == info.Owner {
+		info.Holder = &holder
+		info.holderValue = holderValue
+	}
+	return info, nil
+}
+
+func lockHolderKey(id string) string {
+	return lockHolderKeyPrefix + id
+}
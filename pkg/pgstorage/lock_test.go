@@ -0,0 +1,42 @@
+package pgstorage
+
+import (
+	"github.com/selefra/selefra-provider-sdk/storage/database_storage/postgresql_storage"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLockHolderStale(t *testing.T) {
+	holder := NewLockHolder("owner")
+	require.False(t, holder.Stale())
+
+	dead := *holder
+	dead.Pid = 99999999
+	require.True(t, dead.Stale())
+
+	remote := dead
+	remote.Host = holder.Host + "-remote"
+	require.False(t, remote.Stale())
+}
+
+func TestParseLock(t *testing.T) {
+	expires := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	value := (&postgresql_storage.LockInformation{OwnerId: "owner", LockCount: 1, ExceptedExpireTime: expires}).ToJsonString()
+
+	info, err := parseLock("aws_schema", value, `{"owner":"owner","host":"host","pid":42,"command":"selefra apply"}`)
+	require.NoError(t, err)
+	require.Equal(t, "owner", info.Owner)
+	require.Equal(t, expires, info.ExpiresAt.UTC())
+	require.NotNil(t, info.Holder)
+	require.Equal(t, 42, info.Holder.Pid)
+	require.True(t, info.Expired(expires.Add(time.Second)))
+	require.False(t, info.Expired(expires.Add(-time.Second)))
+
+	info, err = parseLock("aws_schema", value, `{"owner":"former","host":"host","pid":42}`)
+	require.NoError(t, err)
+	require.Nil(t, info.Holder)
+
+	_, err = parseLock("aws_schema", "broken", "")
+	require.Error(t, err)
+}
@@ -12,16 +12,22 @@ import (
 	"github.com/selefra/selefra/pkg/httpClient"
 	"github.com/selefra/selefra/pkg/oci"
 	"github.com/selefra/selefra/ui"
+	"net/url"
+	"strings"
+	"sync"
 )
 
 type Option func(pgopts *postgresql_storage.PostgresqlStorageOptions)
 
-func DefaultPgStorageOpts() *postgresql_storage.PostgresqlStorageOptions {
-	dsn := getDsn()
+func DefaultPgStorageOpts() (*postgresql_storage.PostgresqlStorageOptions, error) {
+	dsn, err := resolveDsn()
+	if err != nil {
+		return nil, err
+	}
 
 	pgopts := postgresql_storage.NewPostgresqlStorageOptions(dsn)
 
-	return pgopts
+	return pgopts, nil
 }
 
 func WithSearchPath(searchPath string) Option {
@@ -30,8 +36,32 @@ func WithSearchPath(searchPath string) Option {
 	}
 }
 
+// WithSearchPaths set the search path of every connection to schemas, unlike WithSearchPath the schemas are not created
+func WithSearchPaths(schemas ...string) Option {
+	return func(pgopts *postgresql_storage.PostgresqlStorageOptions) {
+		pgopts.ConnectionString = withRuntimeParam(pgopts.ConnectionString, "search_path", strings.Join(schemas, ","))
+	}
+}
+
+// withRuntimeParam add a run-time parameter to a dsn in url or keyword/value format
+func withRuntimeParam(dsn, key, value string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(dsn + " " + key + "=" + value)
+}
+
 func PgStorageWithMeta(ctx context.Context, meta *schema.ClientMeta, opts ...Option) (*postgresql_storage.PostgresqlStorage, error) {
-	pgopts := DefaultPgStorageOpts()
+	pgopts, err := DefaultPgStorageOpts()
+	if err != nil {
+		return nil, err
+	}
 
 	for _, opt := range opts {
 		opt(pgopts)
@@ -55,7 +85,10 @@ func PgStorageWithMeta(ctx context.Context, meta *schema.ClientMeta, opts ...Opt
 }
 
 func PgStorage(ctx context.Context, opts ...Option) (*postgresql_storage.PostgresqlStorage, *schema.Diagnostics) {
-	pgopts := DefaultPgStorageOpts()
+	pgopts, err := DefaultPgStorageOpts()
+	if err != nil {
+		return nil, schema.NewDiagnostics().AddError(err)
+	}
 
 	for _, opt := range opts {
 		opt(pgopts)
@@ -65,7 +98,10 @@ func PgStorage(ctx context.Context, opts ...Option) (*postgresql_storage.Postgre
 }
 
 func Storage(ctx context.Context, opts ...Option) (storage.Storage, *schema.Diagnostics) {
-	pgopts := DefaultPgStorageOpts()
+	pgopts, err := DefaultPgStorageOpts()
+	if err != nil {
+		return nil, schema.NewDiagnostics().AddError(err)
+	}
 
 	for _, opt := range opts {
 		opt(pgopts)
@@ -96,22 +132,34 @@ func SetStorageValue(ctx context.Context, storage *postgresql_storage.Postgresql
 	return nil
 }
 
-func getDsn() (dsn string) {
-	var err error
+// getDsn return the dsn of the storage, the priority is:
+// 1. the connection declared in selefra.connection
+// 2. the dsn of selefra cloud when user is login
+// 3. the embedded database
+// an invalid connection or a failure to get the dsn is returned, pgx would connect the libpq defaults with an empty dsn
+func getDsn() (string, error) {
+	db, err := connectionConfig()
+	if err != nil {
+		return "", err
+	}
+	if db != nil {
+		return db.ConnectionString(), nil
+	}
+
 	if global.Token() != "" && global.RelvPrjName() != "" {
-		dsn, err = httpClient.GetDsn(global.Token())
+		dsn, err := httpClient.GetDsn(global.Token())
 		if err != nil {
-			ui.Errorln(err.Error())
-			return ""
+			return "", err
+		}
+		if dsn != "" {
+			return dsn, nil
 		}
 	}
 
-	err = oci.RunDB()
-	if err != nil {
-		ui.Errorln(err.Error())
-		return ""
+	if err := oci.RunDB(); err != nil {
+		return "", err
 	}
-	db := &config.DB{
+	db = &config.DB{
 		Driver:   "",
 		Type:     "postgres",
 		Username: "postgres",
@@ -122,6 +170,38 @@ func getDsn() (dsn string) {
 		SSLMode:  "disable",
 		Extras:   nil,
 	}
-	dsn = "host=" + db.Host + " user=" + db.Username + " password=" + db.Password + " port=" + db.Port + " dbname=" + db.Database + " " + "sslmode=disable"
-	return
+	return db.ConnectionString(), nil
+}
+
+var (
+	dsnOnce     sync.Once
+	resolvedDsn string
+	dsnErr      error
+)
+
+// resolveDsn return the dsn of the storage, it is resolved by getDsn once in a run rather than by every storage
+func resolveDsn() (string, error) {
+	dsnOnce.Do(func() {
+		resolvedDsn, dsnErr = getDsn()
+	})
+	return resolvedDsn, dsnErr
+}
+
+// connectionConfig return the connection declared in selefra.connection, nil if the current directory is not a
+// workspace or the workspace has not declared it, an invalid connection is an error rather than a fallback
+func connectionConfig() (*config.DB, error) {
+	if config.IsSelefra() != nil {
+		return nil, nil
+	}
+	rootConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if rootConfig.Selefra.Connection == nil {
+		return nil, nil
+	}
+	if err := rootConfig.TestConfigByNode(); err != nil {
+		return nil, err
+	}
+	return rootConfig.Selefra.Connection, nil
 }
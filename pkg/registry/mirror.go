@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Platform is an os and arch a provider is built for
+type Platform struct {
+	OS   string
+	Arch string
+}
+
+func (p Platform) String() string {
+	return p.OS + "_" + p.Arch
+}
+
+// platforms is the platforms the registry keeps the archives of providers for
+var platforms = []Platform{
+	{OS: "darwin", Arch: "amd64"},
+	{OS: "darwin", Arch: "arm64"},
+	{OS: "linux", Arch: "amd64"},
+	{OS: "linux", Arch: "arm64"},
+	{OS: "windows", Arch: "amd64"},
+	{OS: "windows", Arch: "arm64"},
+}
+
+// CurrentPlatform return the platform selefra is running on
+func CurrentPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+// ParsePlatform parse a platform written as os_arch or os/arch, such as linux_amd64
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '/'
+	})
+	if len(parts) != 2 {
+		return Platform{}, fmt.Errorf("invalid platform %s, must be os_arch such as linux_amd64", s)
+	}
+	platform := Platform{OS: parts[0], Arch: parts[1]}
+	if _, err := platformChecksum(ProviderSupplement{}, platform); err != nil {
+		return Platform{}, fmt.Errorf("invalid platform %s: %s", s, err.Error())
+	}
+	return platform, nil
+}
+
+// Mirror download provider for the platforms into dir, dir is laid out like the registry so it can be used
+// as a registry by WithURL, the provider with its version resolved is returned
+func (p *provider) Mirror(ctx context.Context, provider Provider, dir string, platforms []Platform) (Provider, error) {
+	metadata, err := p.getProviderMetadata(ctx, &provider)
+	if err != nil {
+		return provider, err
+	}
+	if provider.Version, err = metadata.resolve(provider.Version); err != nil {
+		return provider, err
+	}
+	supplement, err := p.getSupplement(ctx, &provider)
+	if err != nil {
+		return provider, err
+	}
+
+	versionDir := filepath.Join(dir, "provider", provider.Name, provider.Version)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return provider, err
+	}
+	for _, platform := range platforms {
+		if err := p.mirrorArchive(ctx, supplement, provider, platform, versionDir); err != nil {
+			return provider, fmt.Errorf("mirror %s for %s failed: %w", provider.String(), platform, err)
+		}
+	}
+
+	// the archives are next to the supplement in the mirror
+	supplement.Source = ""
+	if err := writeYaml(filepath.Join(versionDir, "supplement.yaml"), supplement); err != nil {
+		return provider, err
+	}
+
+	metadataPath := filepath.Join(dir, "provider", provider.Name, "metadata.yaml")
+	var mirrored ProviderMetadata
+	if b, err := os.ReadFile(metadataPath); err == nil {
+		if err := yaml.Unmarshal(b, &mirrored); err != nil {
+			return provider, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return provider, err
+	}
+	mirrored.Name = metadata.Name
+	mirrored.Introduction = metadata.Introduction
+	if !hasVersion(mirrored.Versions, provider.Version) {
+		mirrored.Versions = append(mirrored.Versions, provider.Version)
+	}
+	if mirrored.LatestVersion == "" || provider.Version == metadata.LatestVersion {
+		mirrored.LatestVersion = provider.Version
+		mirrored.LatestUpdate = metadata.LatestUpdate
+	}
+	if err := writeYaml(metadataPath, mirrored); err != nil {
+		return provider, err
+	}
+	return provider, nil
+}
+
+func hasVersion(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func writeYaml(path string, v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// mirrorArchive download the archive of provider for platform into dir after verifying it,
+// its signature is saved next to it
+func (p *provider) mirrorArchive(ctx context.Context, supplement ProviderSupplement, provider Provider, platform Platform, dir string) error {
+	name := archiveName(supplement, provider.Version, platform)
+	fileName := name + ".tar.gz"
+	archiveURL := p.archiveURL(supplement, provider, name)
+	// the archive is kept in a temporary file until it is verified, so the archive is never left half written
+	path := filepath.Join(dir, fileName)
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+	sum, err := downloadFile(ctx, archiveURL, tmp, provider.String()+" "+platform.String())
+	if err != nil {
+		return err
+	}
+	var signature []byte
+	if !p.skipVerify {
+		if signature, err = p.verifyArchive(ctx, supplement, archiveURL, platform, tmp, sum); err != nil {
+			return err
+		}
+	}
+	if signature != nil {
+		verifier, err := parsePublicKey(p.publicKey)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, fileName+verifier.suffix()), signature); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeFile write data to path through a temporary file, so path is never left half written
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("linux_amd64")
+	require.NoError(t, err)
+	require.Equal(t, Platform{OS: "linux", Arch: "amd64"}, p)
+
+	p, err = ParsePlatform("darwin/arm64")
+	require.NoError(t, err)
+	require.Equal(t, "darwin_arm64", p.String())
+
+	_, err = ParsePlatform("linux")
+	require.Error(t, err)
+	_, err = ParsePlatform("plan9_amd64")
+	require.Error(t, err)
+}
+
+func TestNormalizeURL(t *testing.T) {
+	require.Equal(t, "https://example.com/registry", normalizeURL("https://example.com/registry/"))
+	require.Equal(t, "file:///opt/registry", normalizeURL("file:///opt/registry"))
+
+	dir := t.TempDir()
+	u := normalizeURL(dir)
+	require.True(t, strings.HasPrefix(u, "file://"))
+	path, err := fileURLPath(u)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Clean(dir), path)
+}
+
+func TestMirror(t *testing.T) {
+	src := t.TempDir()
+	versionDir := filepath.Join(src, "provider", "aws", "v0.0.2")
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+	require.NoError(t, writeYaml(filepath.Join(src, "provider", "aws", "metadata.yaml"), ProviderMetadata{
+		Name:          "aws",
+		LatestVersion: "v0.0.2",
+		Versions:      []string{"v0.0.1", "v0.0.2"},
+	}))
+	sum := sha256.Sum256([]byte("archive"))
+	require.NoError(t, writeYaml(filepath.Join(versionDir, "supplement.yaml"), ProviderSupplement{
+		PackageName: "selefra-provider-aws",
+		Checksums:   Checksums{LinuxAmd64: hex.EncodeToString(sum[:]), DarwinArm64: "bad"},
+	}))
+	archive := "selefra-provider-aws_0.0.2_linux_amd64.tar.gz"
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, archive), []byte("archive"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "selefra-provider-aws_0.0.2_darwin_arm64.tar.gz"), []byte("tampered"), 0644))
+
+	dst := t.TempDir()
+	p := NewProviderRegistry(t.TempDir(), WithURL(src))
+	mirrored, err := p.Mirror(context.Background(), Provider{Name: "aws", Version: "latest"}, dst, []Platform{{OS: "linux", Arch: "amd64"}})
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.2", mirrored.Version)
+
+	b, err := os.ReadFile(filepath.Join(dst, "provider", "aws", "v0.0.2", archive))
+	require.NoError(t, err)
+	require.Equal(t, "archive", string(b))
+
+	var metadata ProviderMetadata
+	b, err = os.ReadFile(filepath.Join(dst, "provider", "aws", "metadata.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(b, &metadata))
+	require.Equal(t, "v0.0.2", metadata.LatestVersion)
+	require.Equal(t, []string{"v0.0.2"}, metadata.Versions)
+
+	_, err = p.Mirror(context.Background(), Provider{Name: "aws", Version: "v0.0.2"}, dst, []Platform{{OS: "darwin", Arch: "arm64"}})
+	require.ErrorIs(t, err, ErrVerifyFailed)
+	_, err = os.Stat(filepath.Join(dst, "provider", "aws", "v0.0.2", "selefra-provider-aws_0.0.2_darwin_arm64.tar.gz"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = p.Mirror(context.Background(), Provider{Name: "aws", Version: "v0.0.1"}, dst, []Platform{{OS: "linux", Arch: "amd64"}})
+	require.Error(t, err)
+
+	_, err = p.Mirror(context.Background(), Provider{Name: "aws", Version: "v0.0.3"}, dst, nil)
+	require.Error(t, err)
+}
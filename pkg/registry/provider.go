@@ -2,10 +2,13 @@ package registry
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -16,10 +19,17 @@ import (
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/selefra/selefra/pkg/internal/getter"
+	"github.com/selefra/selefra/ui/progress"
 )
 
 const (
 	row = "https://raw.githubusercontent.com/selefra/registry"
+
+	// defaultURL is the base url of the official registry
+	defaultURL = row + "/main"
+
+	// RegistryEnv is the environment variable which overrides the base url of the registry
+	RegistryEnv = "SELEFRA_REGISTRY"
 )
 
 var (
@@ -46,6 +56,11 @@ type RegisterProvider interface {
 	CheckUpdate(ctx context.Context, binary ProviderBinary) (ProviderBinary, error)
 	Download(ctx context.Context, provider Provider, skipVerify bool) (ProviderBinary, error)
 	DeleteProvider(binary ProviderBinary) error
+	Mirror(ctx context.Context, provider Provider, dir string, platforms []Platform) (Provider, error)
+	Checksums(ctx context.Context, provider Provider) (map[string]string, error)
+	List(ctx context.Context) ([]string, error)
+	Search(ctx context.Context, term string) ([]ProviderMetadata, error)
+	Metadata(ctx context.Context, name string) (ProviderMetadata, error)
 }
 
 type Providers struct {
@@ -128,8 +143,173 @@ func request(ctx context.Context, method string, _url string, body []byte, heade
 
 // =====================================================================================================================
 
+// get read a file of the registry, a file url reads the local file
+func get(ctx context.Context, _url string) ([]byte, error) {
+	if strings.HasPrefix(_url, "file://") {
+		path, err := fileURLPath(_url)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(path)
+	}
+	return request(ctx, "GET", _url, nil)
+}
+
+// open open a file of the registry for reading, size is -1 when it is unknown, a file url opens the local file
+func open(ctx context.Context, _url string) (body io.ReadCloser, size int64, err error) {
+	if strings.HasPrefix(_url, "file://") {
+		path, err := fileURLPath(_url)
+		if err != nil {
+			return nil, 0, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, info.Size(), nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", _url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := (&http.Client{}).Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, errors.New("code not equal 200")
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// downloadFile save the file of the registry at _url to path showing a progress bar of name,
+// the sha256 of the file is computed while it is written, so the file is never read into memory
+func downloadFile(ctx context.Context, _url, path, name string) ([]byte, error) {
+	body, size, err := open(ctx, _url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	progbar := progress.CreateProgress()
+	reader := progbar.ReaderBar(name, body, size)
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), reader)
+	if err != nil {
+		progbar.Abort(name)
+	} else {
+		progbar.Complete(name)
+	}
+	progbar.Wait(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// fileURLPath return the local path of a file url
+func fileURLPath(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	path := u.Path
+	// file:///C:/registry on windows
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// normalizeURL return the base url of a registry, a local directory is turned into a file url
+func normalizeURL(u string) string {
+	u = strings.TrimSuffix(strings.TrimSpace(u), "/")
+	if strings.Contains(u, "://") {
+		return u
+	}
+	abs, err := filepath.Abs(u)
+	if err != nil {
+		return u
+	}
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+// Option change the registry providers are downloaded from
+type Option func(p *provider)
+
+// WithURL download providers from the registry at url, it is a http url, a file url or a local directory
+// laid out like the official registry, an empty url is ignored
+func WithURL(registryURL string) Option {
+	return func(p *provider) {
+		if registryURL != "" {
+			p.url = normalizeURL(registryURL)
+		}
+	}
+}
+
+// WithPublicKey require the archives of providers to be signed with key, it is a minisign public key or
+// a cosign PEM public key, the content or the path of its file. the signature is the file next to an archive
+// with the suffix .minisig for minisign and .sig for cosign
+func WithPublicKey(key string) Option {
+	return func(p *provider) {
+		p.publicKey = key
+	}
+}
+
+// WithSkipVerify skip the checksum and signature verification of the archives if skip, it is meant for
+// the development of providers
+func WithSkipVerify(skip bool) Option {
+	return func(p *provider) {
+		p.skipVerify = skip
+	}
+}
+
 type provider struct {
-	namespace string
+	namespace  string
+	url        string
+	publicKey  string
+	skipVerify bool
+}
+
+func (p *provider) metadataURL(name string) string {
+	return p.url + "/provider/" + name + "/metadata.yaml"
+}
+
+func (p *provider) supplementURL(name, version string) string {
+	return p.url + "/provider/" + name + "/" + version + "/supplement.yaml"
+}
+
+// archiveURL return the url of the archive of a provider, a supplement without source means
+// the archives are kept in the registry next to it, such as a mirror
+func (p *provider) archiveURL(supplement ProviderSupplement, provider Provider, fileName string) string {
+	if supplement.Source == "" {
+		return p.url + "/provider/" + provider.Name + "/" + provider.Version + "/" + fileName + ".tar.gz"
+	}
+	return supplement.Source + "/releases/download/" + provider.Version + "/" + fileName + ".tar.gz"
+}
+
+// archiveName return the name of the archive of a provider for a platform without extension
+func archiveName(supplement ProviderSupplement, version string, platform Platform) string {
+	return supplement.PackageName + "_" + strings.Replace(version, "v", "", 1) + "_" + platform.OS + "_" + platform.Arch
 }
 
 func (p *provider) CheckUpdate(ctx context.Context, binary ProviderBinary) (ProviderBinary, error) {
@@ -137,15 +317,35 @@ func (p *provider) CheckUpdate(ctx context.Context, binary ProviderBinary) (Prov
 	if err != nil {
 		return ProviderBinary{}, err
 	}
-	if binary.Provider.Version == metadata.LatestVersion {
-		return ProviderBinary{}, nil
+	version, err := metadata.resolve(binary.Provider.Version)
+	if err != nil {
+		return ProviderBinary{}, err
+	}
+	if InstalledVersion(binary.Filepath) == version {
+		binary.Provider.Version = version
+		return binary, nil
 	}
-	if binary.Provider.Version != "" && binary.Provider.Version != "latest" {
+	if binary.Provider.Version != "" && binary.Provider.Version != "latest" && !IsConstraint(binary.Provider.Version) {
 		_ = p.deleteProviderBinary(binary)
 	}
 
-	binary.Provider.Version = metadata.LatestVersion
-	return p.download(ctx, binary.Provider, true)
+	binary.Provider.Version = version
+	return p.download(ctx, binary.Provider, false)
+}
+
+// Checksums return the sha256 of the archives of provider by platform such as linux_amd64
+func (p *provider) Checksums(ctx context.Context, provider Provider) (map[string]string, error) {
+	supplement, err := p.getSupplement(ctx, &provider)
+	if err != nil {
+		return nil, err
+	}
+	var checksums = make(map[string]string)
+	for _, platform := range platforms {
+		if checksum, _ := platformChecksum(supplement, platform); checksum != "" {
+			checksums[platform.String()] = checksum
+		}
+	}
+	return checksums, nil
 }
 
 func (p *provider) DeleteProvider(binary ProviderBinary) error {
@@ -162,9 +362,7 @@ func (p *provider) deleteProviderBinary(binary ProviderBinary) error {
 
 func (p *provider) getSupplement(ctx context.Context, provider *Provider) (ProviderSupplement, error) {
 	var supplement ProviderSupplement
-	_url := row + "/main/provider/" + provider.Name + "/" + provider.Version + "/" + "supplement.yaml"
-
-	body, err := request(ctx, "GET", _url, nil)
+	body, err := get(ctx, p.supplementURL(provider.Name, provider.Version))
 	if err != nil {
 		return supplement, err
 	}
@@ -174,8 +372,9 @@ func (p *provider) getSupplement(ctx context.Context, provider *Provider) (Provi
 	//downloadUrl := supplement.Supplement.Source + "/releases/download/" + provider.Version + "/" + provider.Name + "_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
 }
 
+// fillVersion resolve the version of provider, latest and constraints are resolved by the metadata of the registry
 func (p *provider) fillVersion(ctx context.Context, provider *Provider, skipVerify bool) error {
-	if provider.Version != "" && provider.Version != "latest" && skipVerify {
+	if provider.Version != "" && provider.Version != "latest" && !IsConstraint(provider.Version) && skipVerify {
 		return nil
 	}
 
@@ -183,24 +382,17 @@ func (p *provider) fillVersion(ctx context.Context, provider *Provider, skipVeri
 	if err != nil {
 		return err
 	}
-	if provider.Version != "" && provider.Version != "latest" {
-		for _, version := range metadata.Versions {
-			if provider.Version != version {
-				continue
-			}
-			return nil
-		}
-		return errors.New("version not found")
+	version, err := metadata.resolve(provider.Version)
+	if err != nil {
+		return err
 	}
-	provider.Version = metadata.LatestVersion
+	provider.Version = version
 	return nil
 }
 
 func (p *provider) getProviderMetadata(ctx context.Context, provider *Provider) (ProviderMetadata, error) {
 	var metadata ProviderMetadata
-	_url := row + "/main/provider/" + provider.Name + "/metadata.yaml"
-
-	body, err := request(ctx, "GET", _url, nil)
+	body, err := get(ctx, p.metadataURL(provider.Name))
 	if err != nil {
 		return metadata, err
 	}
@@ -209,19 +401,44 @@ func (p *provider) getProviderMetadata(ctx context.Context, provider *Provider)
 	return metadata, err
 }
 
-func (p *provider) verify(supplement ProviderSupplement, skipVerify bool) bool {
-	if skipVerify {
-		return true
+// verifyArchive check the archive of provider for platform downloaded from archiveURL to archivePath against
+// its checksum in the registry and its signature if a public key is set, sum is the sha256 of the archive,
+// the signature is returned
+func (p *provider) verifyArchive(ctx context.Context, supplement ProviderSupplement, archiveURL string, platform Platform, archivePath string, sum []byte) ([]byte, error) {
+	checksum, err := platformChecksum(supplement, platform)
+	if err != nil {
+		return nil, err
 	}
-	// TODO Not realized
-	return true
+	if err := verifyChecksum(sum, checksum); err != nil {
+		return nil, err
+	}
+	if p.publicKey == "" {
+		return nil, nil
+	}
+	verifier, err := parsePublicKey(p.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := get(ctx, archiveURL+verifier.suffix())
+	if err != nil {
+		return nil, fmt.Errorf("%w: get signature of the archive failed: %s", ErrVerifyFailed, err.Error())
+	}
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	if err := verifier.verify(archive, signature); err != nil {
+		return nil, err
+	}
+	return signature, nil
 }
 
 func (p *provider) Download(ctx context.Context, provider Provider, skipVerify bool) (ProviderBinary, error) {
 
 	var pp ProviderBinary
 
-	if provider.Version == "" || provider.Version == "latest" {
+	if provider.Version == "" || provider.Version == "latest" || IsConstraint(provider.Version) {
 		err := p.fillVersion(ctx, &provider, skipVerify)
 		if err != nil {
 			return pp, err
@@ -245,44 +462,74 @@ func (p *provider) download(ctx context.Context, provider Provider, skipVerify b
 		return pp, err
 	}
 
-	if !p.verify(supplement, skipVerify) {
-		return pp, errors.New("verify failed")
-	}
-
 	pp.Filepath = abs + "/download/providers/" + provider.Name + "_" + provider.Version + "/"
-	fileName := supplement.PackageName + "_" + strings.Replace(provider.Version, "v", "", 1) + "_" + runtime.GOOS + "_" + runtime.GOARCH
+	fileName := archiveName(supplement, provider.Version, CurrentPlatform())
 	_, err = os.Stat(filepath.Join(pp.Filepath, supplement.PackageName, suffix))
 	if err == nil {
 		pp.Filepath = filepath.Join(pp.Filepath, supplement.PackageName, suffix)
 		return pp, nil
 	}
 
-	getUrl := supplement.Source + "/releases/download/" + provider.Version + "/" + fileName + ".tar.gz"
-
-	if !skipVerify {
-		checksum, err := selectChecksums(supplement)
-		if err != nil {
-			return pp, err
-		}
-		getUrl = getUrl + "?checksum=sha256:" + checksum
+	// the archive is downloaded into a temporary directory next to the provider directory, which is replaced
+	// only once the archive is verified and unpacked, so a failed download leaves no partial provider behind
+	dir := filepath.Clean(pp.Filepath)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return ProviderBinary{}, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".tmp")
+	if err != nil {
+		return ProviderBinary{}, err
 	}
+	defer os.RemoveAll(tmp)
 
-	err = getter.Get(ctx, pp.Filepath, getUrl)
+	getUrl := p.archiveURL(supplement, provider, fileName)
+	archivePath := filepath.Join(tmp, fileName+".tar.gz")
+	sum, err := downloadFile(ctx, getUrl, archivePath, provider.String())
 	if err != nil {
+		return ProviderBinary{}, fmt.Errorf("download %s failed: %s", getUrl, err.Error())
+	}
+	if !skipVerify && !p.skipVerify {
+		if _, err := p.verifyArchive(ctx, supplement, getUrl, CurrentPlatform(), archivePath, sum); err != nil {
+			return ProviderBinary{}, fmt.Errorf("%s: %w", provider.String(), err)
+		}
+	}
+	if err := extract(dir, archivePath); err != nil {
 		return ProviderBinary{}, err
 	}
-	pp.Filepath = filepath.Join(pp.Filepath, supplement.PackageName, suffix)
+	pp.Filepath = filepath.Join(dir, supplement.PackageName, suffix)
 	return pp, nil
 }
 
-func NewProviderRegistry(namespace string) RegisterProvider {
-	return &provider{namespace: namespace}
+// extract unpack the archive file at archivePath into dir, the archive is unpacked next to it and moved to dir,
+// so archivePath must be on the file system of dir
+func extract(dir, archivePath string) error {
+	unpacked := filepath.Join(filepath.Dir(archivePath), "unpacked")
+	if err := getter.Decompress(unpacked, archivePath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(unpacked, dir)
+}
+
+// NewProviderRegistry return the registry to download providers from, it is the official registry unless
+// another one is given by WithURL. the environment variable SELEFRA_REGISTRY takes precedence over both
+func NewProviderRegistry(namespace string, opts ...Option) RegisterProvider {
+	p := &provider{namespace: namespace, url: defaultURL}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if u := os.Getenv(RegistryEnv); u != "" {
+		p.url = normalizeURL(u)
+	}
+	return p
 }
 
-func selectChecksums(supplement ProviderSupplement) (string, error) {
-	switch runtime.GOOS {
+func platformChecksum(supplement ProviderSupplement, platform Platform) (string, error) {
+	switch platform.OS {
 	case "darwin":
-		switch runtime.GOARCH {
+		switch platform.Arch {
 		case "amd64":
 			return supplement.Checksums.DarwinAmd64, nil
 		case "arm64":
@@ -291,7 +538,7 @@ func selectChecksums(supplement ProviderSupplement) (string, error) {
 			return "", errors.New("unsupported arch")
 		}
 	case "windows":
-		switch runtime.GOARCH {
+		switch platform.Arch {
 		case "amd64":
 			return supplement.Checksums.WindowsAmd64, nil
 		case "arm64":
@@ -300,7 +547,7 @@ func selectChecksums(supplement ProviderSupplement) (string, error) {
 			return "", errors.New("unsupported arch")
 		}
 	case "linux":
-		switch runtime.GOARCH {
+		switch platform.Arch {
 		case "amd64":
 			return supplement.Checksums.LinuxAmd64, nil
 		case "arm64":
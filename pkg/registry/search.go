@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fileListURL is the page of the official registry on github which lists its providers
+const fileListURL = "https://github.com/selefra/registry/file-list/main/provider"
+
+// templateProvider is the template of the providers in the official registry rather than a provider
+const templateProvider = "template"
+
+// List return the names of the providers in the registry sorted, it is supported by the official registry
+// and the registries in a local directory such as a mirror
+func (p *provider) List(ctx context.Context) ([]string, error) {
+	var names []string
+	switch {
+	case strings.HasPrefix(p.url, "file://"):
+		dir, err := fileURLPath(p.url + "/provider")
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, entry.Name(), "metadata.yaml")); err == nil {
+				names = append(names, entry.Name())
+			}
+		}
+	case p.url == defaultURL:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileListURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		d, err := goquery.NewDocumentFromReader(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		d.Find(".js-navigation-open.Link--primary").Each(func(i int, s *goquery.Selection) {
+			if s.Text() != templateProvider {
+				names = append(names, s.Text())
+			}
+		})
+	default:
+		return nil, fmt.Errorf("the registry %s does not support listing providers", p.url)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Search return the metadata of the providers in the registry whose name or introduction contains term,
+// case is ignored and an empty term matches all the providers
+func (p *provider) Search(ctx context.Context, term string) ([]ProviderMetadata, error) {
+	names, err := p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	term = strings.ToLower(term)
+	var matched []ProviderMetadata
+	for _, name := range names {
+		metadata, err := p.Metadata(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("get metadata of %s failed: %s", name, err.Error())
+		}
+		if metadata.Name == "" {
+			metadata.Name = name
+		}
+		if strings.Contains(strings.ToLower(name), term) || strings.Contains(strings.ToLower(metadata.Introduction), term) {
+			matched = append(matched, metadata)
+		}
+	}
+	return matched, nil
+}
+
+// Metadata return the metadata of the provider name in the registry
+func (p *provider) Metadata(ctx context.Context, name string) (ProviderMetadata, error) {
+	return p.getProviderMetadata(ctx, &Provider{Name: name})
+}
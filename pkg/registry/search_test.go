@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	src := t.TempDir()
+	for name, introduction := range map[string]string{
+		"aws":   "Amazon Web Services",
+		"gcp":   "Google Cloud Platform",
+		"azure": "Microsoft Azure",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "provider", name), 0755))
+		require.NoError(t, writeYaml(filepath.Join(src, "provider", name, "metadata.yaml"), ProviderMetadata{
+			Name:          name,
+			LatestVersion: "v0.0.1",
+			Introduction:  introduction,
+			Versions:      []string{"v0.0.1"},
+		}))
+	}
+	// a directory without metadata is not a provider
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "provider", "template"), 0755))
+
+	ctx := context.Background()
+	p := NewProviderRegistry(t.TempDir(), WithURL(src))
+	names, err := p.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"aws", "azure", "gcp"}, names)
+
+	matched, err := p.Search(ctx, "cloud")
+	require.NoError(t, err)
+	require.Len(t, matched, 1)
+	require.Equal(t, "gcp", matched[0].Name)
+
+	matched, err = p.Search(ctx, "MICROSOFT")
+	require.NoError(t, err)
+	require.Len(t, matched, 1)
+	require.Equal(t, "azure", matched[0].Name)
+
+	matched, err = p.Search(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, matched, 3)
+
+	metadata, err := p.Metadata(ctx, "aws")
+	require.NoError(t, err)
+	require.Equal(t, "Amazon Web Services", metadata.Introduction)
+
+	_, err = NewProviderRegistry(t.TempDir(), WithURL("https://registry.example.com")).List(ctx)
+	require.Error(t, err)
+}
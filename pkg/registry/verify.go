@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// minisignSuffix is the suffix of the minisign signature file next to an archive
+	minisignSuffix = ".minisig"
+	// cosignSuffix is the suffix of the cosign signature file next to an archive, made by cosign sign-blob
+	cosignSuffix = ".sig"
+)
+
+// ErrVerifyFailed is returned when a downloaded archive does not match its checksum or signature
+var ErrVerifyFailed = errors.New("verify failed")
+
+// signatureVerifier check the detached signature of an archive
+type signatureVerifier interface {
+	// suffix return the suffix of the signature file next to an archive
+	suffix() string
+	// verify check signature against the archive read from archive
+	verify(archive io.Reader, signature []byte) error
+}
+
+// verifyChecksum check sum, the sha256 of an archive, equals checksum, which is hex encoded
+func verifyChecksum(sum []byte, checksum string) error {
+	checksum = strings.TrimPrefix(strings.TrimSpace(checksum), "sha256:")
+	if checksum == "" {
+		return fmt.Errorf("%w: the registry has no checksum of the archive", ErrVerifyFailed)
+	}
+	if actual := hex.EncodeToString(sum); !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf("%w: sha256 of the archive is %s, expected %s", ErrVerifyFailed, actual, checksum)
+	}
+	return nil
+}
+
+// parsePublicKey parse a minisign or cosign public key, key is the content of the key or the path of its file
+func parsePublicKey(key string) (signatureVerifier, error) {
+	key = strings.TrimSpace(key)
+	if b, err := os.ReadFile(key); err == nil {
+		key = strings.TrimSpace(string(b))
+	}
+	if strings.HasPrefix(key, "-----BEGIN") {
+		return parseCosignKey(key)
+	}
+	return parseMinisignKey(key)
+}
+
+// minisignKey is a minisign public key, see https://jedisct1.github.io/minisign/
+type minisignKey struct {
+	keyId [8]byte
+	key   ed25519.PublicKey
+}
+
+func parseMinisignKey(key string) (*minisignKey, error) {
+	lines := strings.Split(key, "\n")
+	// the key file starts with an untrusted comment
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(b) != 42 || string(b[:2]) != "Ed" {
+		return nil, errors.New("invalid public key, it must be a minisign public key or a cosign PEM public key")
+	}
+	k := &minisignKey{key: ed25519.PublicKey(b[10:])}
+	copy(k.keyId[:], b[2:10])
+	return k, nil
+}
+
+func (k *minisignKey) suffix() string {
+	return minisignSuffix
+}
+
+// verify check a minisign signature, the archive is read into memory only for the legacy signatures
+// which are not prehashed
+func (k *minisignKey) verify(archive io.Reader, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("%w: invalid minisign signature", ErrVerifyFailed)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 74 {
+		return fmt.Errorf("%w: invalid minisign signature", ErrVerifyFailed)
+	}
+	if !bytes.Equal(sig[2:10], k.keyId[:]) {
+		return fmt.Errorf("%w: the archive is signed by another key", ErrVerifyFailed)
+	}
+
+	var message []byte
+	switch string(sig[:2]) {
+	case "Ed":
+		if message, err = io.ReadAll(archive); err != nil {
+			return err
+		}
+	case "ED":
+		// the archive is prehashed
+		h, err := blake2b.New512(nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(h, archive); err != nil {
+			return err
+		}
+		message = h.Sum(nil)
+	default:
+		return fmt.Errorf("%w: unsupported minisign signature algorithm", ErrVerifyFailed)
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return fmt.Errorf("%w: invalid signature of the archive", ErrVerifyFailed)
+	}
+
+	// the global signature covers the signature and the trusted comment
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return fmt.Errorf("%w: invalid minisign signature", ErrVerifyFailed)
+	}
+	trustedComment := strings.TrimSuffix(strings.TrimPrefix(lines[2], "trusted comment: "), "\r")
+	if !ed25519.Verify(k.key, append(sig[10:], trustedComment...), globalSig) {
+		return fmt.Errorf("%w: invalid trusted comment of the signature", ErrVerifyFailed)
+	}
+	return nil
+}
+
+// cosignKey is an ECDSA public key of cosign, the signature is made by cosign sign-blob
+type cosignKey struct {
+	key *ecdsa.PublicKey
+}
+
+func parseCosignKey(key string) (*cosignKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported public key, cosign keys are ECDSA keys")
+	}
+	return &cosignKey{key: ecdsaKey}, nil
+}
+
+func (k *cosignKey) suffix() string {
+	return cosignSuffix
+}
+
+func (k *cosignKey) verify(archive io.Reader, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("%w: invalid cosign signature", ErrVerifyFailed)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, archive); err != nil {
+		return err
+	}
+	if !ecdsa.VerifyASN1(k.key, h.Sum(nil), sig) {
+		return fmt.Errorf("%w: invalid signature of the archive", ErrVerifyFailed)
+	}
+	return nil
+}
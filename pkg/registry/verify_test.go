@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("archive"))
+	tampered := sha256.Sum256([]byte("tampered"))
+	require.NoError(t, verifyChecksum(sum[:], hex.EncodeToString(sum[:])))
+	require.NoError(t, verifyChecksum(sum[:], "sha256:"+hex.EncodeToString(sum[:])))
+	require.ErrorIs(t, verifyChecksum(tampered[:], hex.EncodeToString(sum[:])), ErrVerifyFailed)
+	require.ErrorIs(t, verifyChecksum(sum[:], ""), ErrVerifyFailed)
+}
+
+// minisign sign archive like minisign -S, prehashed
+func minisign(t *testing.T) (publicKey string, sign func(archive []byte) []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyId := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicKey = "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyId...), pub...))
+	sign = func(archive []byte) []byte {
+		sum := blake2b.Sum512(archive)
+		sig := append(append([]byte("ED"), keyId...), ed25519.Sign(priv, sum[:])...)
+		trustedComment := "timestamp:1670000000"
+		globalSig := ed25519.Sign(priv, append(append([]byte{}, sig[10:]...), trustedComment...))
+		return []byte("untrusted comment: signature\n" + base64.StdEncoding.EncodeToString(sig) + "\n" +
+			"trusted comment: " + trustedComment + "\n" + base64.StdEncoding.EncodeToString(globalSig) + "\n")
+	}
+	return publicKey, sign
+}
+
+func TestMinisign(t *testing.T) {
+	publicKey, sign := minisign(t)
+	verifier, err := parsePublicKey(publicKey)
+	require.NoError(t, err)
+	require.Equal(t, minisignSuffix, verifier.suffix())
+
+	signature := sign([]byte("archive"))
+	require.NoError(t, verifier.verify(strings.NewReader("archive"), signature))
+	require.ErrorIs(t, verifier.verify(strings.NewReader("tampered"), signature), ErrVerifyFailed)
+
+	otherKey, _ := minisign(t)
+	other, err := parsePublicKey(otherKey)
+	require.NoError(t, err)
+	require.ErrorIs(t, other.verify(strings.NewReader("archive"), signature), ErrVerifyFailed)
+
+	_, err = parsePublicKey("not a key")
+	require.Error(t, err)
+}
+
+func TestCosign(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "cosign.pub")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+
+	verifier, err := parsePublicKey(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, cosignSuffix, verifier.suffix())
+
+	sum := sha256.Sum256([]byte("archive"))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, sum[:])
+	require.NoError(t, err)
+	signature := []byte(base64.StdEncoding.EncodeToString(sig))
+	require.NoError(t, verifier.verify(strings.NewReader("archive"), signature))
+	require.ErrorIs(t, verifier.verify(strings.NewReader("tampered"), signature), ErrVerifyFailed)
+}
+
+func tarGz(t *testing.T, name, content string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestDownloadVerify(t *testing.T) {
+	src := t.TempDir()
+	versionDir := filepath.Join(src, "provider", "aws", "v0.0.2")
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+	archive := tarGz(t, "selefra-provider-aws", "binary")
+	sum := sha256.Sum256(archive)
+	var checksums Checksums
+	checksums.LinuxAmd64, checksums.LinuxArm64 = hex.EncodeToString(sum[:]), hex.EncodeToString(sum[:])
+	checksums.DarwinAmd64, checksums.DarwinArm64 = hex.EncodeToString(sum[:]), hex.EncodeToString(sum[:])
+	checksums.WindowsAmd64, checksums.WindowsArm64 = hex.EncodeToString(sum[:]), hex.EncodeToString(sum[:])
+	require.NoError(t, writeYaml(filepath.Join(versionDir, "supplement.yaml"), ProviderSupplement{PackageName: "selefra-provider-aws", Checksums: checksums}))
+	archivePath := filepath.Join(versionDir, archiveName(ProviderSupplement{PackageName: "selefra-provider-aws"}, "v0.0.2", CurrentPlatform())+".tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, archive, 0644))
+
+	publicKey, sign := minisign(t)
+	ctx := context.Background()
+	namespace := t.TempDir()
+	providerDir := filepath.Join(namespace, "download", "providers", "aws_v0.0.2")
+
+	// the signature is required once a public key is set
+	p := NewProviderRegistry(namespace, WithURL(src), WithPublicKey(publicKey))
+	_, err := p.Download(ctx, Provider{Name: "aws", Version: "v0.0.2"}, false)
+	require.ErrorIs(t, err, ErrVerifyFailed)
+	_, err = os.Stat(providerDir)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.WriteFile(archivePath+minisignSuffix, sign(archive), 0644))
+	binary, err := p.Download(ctx, Provider{Name: "aws", Version: "v0.0.2"}, false)
+	require.NoError(t, err)
+	b, err := os.ReadFile(binary.Filepath)
+	require.NoError(t, err)
+	require.Equal(t, "binary", string(b))
+	require.NoError(t, os.RemoveAll(providerDir))
+
+	// a tampered archive is rejected and leaves nothing behind
+	require.NoError(t, os.WriteFile(archivePath, tarGz(t, "selefra-provider-aws", "tampered"), 0644))
+	_, err = NewProviderRegistry(namespace, WithURL(src)).Download(ctx, Provider{Name: "aws", Version: "v0.0.2"}, false)
+	require.ErrorIs(t, err, ErrVerifyFailed)
+	_, err = os.Stat(providerDir)
+	require.True(t, os.IsNotExist(err))
+	entries, err := os.ReadDir(filepath.Dir(providerDir))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	_, err = NewProviderRegistry(namespace, WithURL(src), WithSkipVerify(true)).Download(ctx, Provider{Name: "aws", Version: "v0.0.2"}, false)
+	require.NoError(t, err)
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// IsConstraint return true if v is a version constraint such as ">= 0.0.9, < 0.1" or "~> 0.0.12"
+// rather than an exact version or latest
+func IsConstraint(v string) bool {
+	return strings.ContainsAny(v, "<>=~!,")
+}
+
+// ResolveVersion return the highest of versions matching constraint
+func ResolveVersion(constraint string, versions []string) (string, error) {
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %s", constraint, err.Error())
+	}
+	var matched []*version.Version
+	var originals = make(map[*version.Version]string)
+	for _, v := range versions {
+		parsed, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if constraints.Check(parsed) {
+			matched = append(matched, parsed)
+			originals[parsed] = v
+		}
+	}
+	if len(matched) == 0 {
+		return "", fmt.Errorf("no version matches %q", constraint)
+	}
+	sort.Sort(version.Collection(matched))
+	return originals[matched[len(matched)-1]], nil
+}
+
+// LatestConstraint return the constraint latest is resolved with once installed is installed, so latest
+// never moves to a new major version silently. a 0.x version is kept in its minor version, as the minor
+// version of 0.x is the major one. it returns empty if installed is not a version
+func LatestConstraint(installed string) string {
+	v, err := version.NewVersion(installed)
+	if err != nil {
+		return ""
+	}
+	segments := v.Segments()
+	if segments[0] > 0 {
+		return fmt.Sprintf(">= %s, < %d.0.0", v.String(), segments[0]+1)
+	}
+	return fmt.Sprintf(">= %s, < 0.%d.0", v.String(), segments[1]+1)
+}
+
+// InstalledVersion return the version of the provider binary downloaded to path, empty if path is not
+// downloaded from a registry
+func InstalledVersion(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := 2; i < len(parts); i++ {
+		if parts[i-2] != "download" || parts[i-1] != "providers" {
+			continue
+		}
+		if index := strings.LastIndex(parts[i], "_"); index >= 0 {
+			return parts[i][index+1:]
+		}
+	}
+	return ""
+}
+
+// resolve return the version of the provider version v is resolved to, latest is the latest version,
+// a constraint is the highest version matching it and an exact version must be a version of the provider
+func (m ProviderMetadata) resolve(v string) (string, error) {
+	switch {
+	case v == "" || v == "latest":
+		return m.LatestVersion, nil
+	case IsConstraint(v):
+		resolved, err := ResolveVersion(v, m.Versions)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", m.Name, err.Error())
+		}
+		return resolved, nil
+	case hasVersion(m.Versions, v):
+		return v, nil
+	default:
+		return "", fmt.Errorf("version %s of %s not found", v, m.Name)
+	}
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveVersion(t *testing.T) {
+	versions := []string{"v0.0.8", "v0.0.9", "v0.0.12", "v0.0.13", "v0.1.0", "v1.0.0", "v1.2.0"}
+
+	v, err := ResolveVersion(">= 0.0.9, < 0.1", versions)
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.13", v)
+
+	v, err = ResolveVersion("~> 0.0.12", versions)
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.13", v)
+
+	v, err = ResolveVersion("~> 1.0", versions)
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.0", v)
+
+	_, err = ResolveVersion(">= 2.0", versions)
+	require.Error(t, err)
+	_, err = ResolveVersion(">= x", versions)
+	require.Error(t, err)
+}
+
+func TestIsConstraint(t *testing.T) {
+	require.True(t, IsConstraint(">= 0.0.9, < 0.1"))
+	require.True(t, IsConstraint("~> 0.0.12"))
+	require.False(t, IsConstraint("v0.0.9"))
+	require.False(t, IsConstraint("latest"))
+}
+
+func TestLatestConstraint(t *testing.T) {
+	versions := []string{"v0.0.9", "v0.0.12", "v0.1.0", "v1.0.0", "v1.2.0", "v2.0.0"}
+
+	v, err := ResolveVersion(LatestConstraint("v0.0.9"), versions)
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.12", v)
+
+	v, err = ResolveVersion(LatestConstraint("v1.0.0"), versions)
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.0", v)
+
+	require.Equal(t, "", LatestConstraint(""))
+}
+
+func TestInstalledVersion(t *testing.T) {
+	path := filepath.Join("home", ".selefra", "download", "providers", "aws_v0.0.9", "selefra-provider-aws")
+	require.Equal(t, "v0.0.9", InstalledVersion(path))
+	require.Equal(t, "", InstalledVersion(filepath.Join("home", "bin", "selefra-provider-aws")))
+	require.Equal(t, "", InstalledVersion(""))
+}
+
+func TestMetadataResolve(t *testing.T) {
+	metadata := ProviderMetadata{Name: "aws", LatestVersion: "v1.0.0", Versions: []string{"v0.0.9", "v0.0.12", "v1.0.0"}}
+
+	v, err := metadata.resolve("latest")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", v)
+
+	v, err = metadata.resolve("~> 0.0.9")
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.12", v)
+
+	v, err = metadata.resolve("v0.0.9")
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.9", v)
+
+	_, err = metadata.resolve("v0.0.10")
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Baseline is the issue set of a previous run, issues in it are not reported as new
+type Baseline struct {
+	Issues       []*Issue
+	fingerprints map[string]bool
+}
+
+// NewBaseline build a baseline from the issues of a previous run
+func NewBaseline(issues []*Issue) *Baseline {
+	b := &Baseline{
+		Issues:       issues,
+		fingerprints: make(map[string]bool),
+	}
+	for _, issue := range issues {
+		b.fingerprints[issue.Fingerprint] = true
+	}
+	return b
+}
+
+// ReadBaseline build a baseline from a report written in json format, the unchanged issues of a report written
+// with a baseline are a part of the baseline too
+func ReadBaseline(path string) (*Baseline, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return NewBaseline(append(r.Issues, r.Unchanged...)), nil
+}
+
+// Has return true if the issue of fingerprint is in the baseline, a nil baseline has no issue
+func (b *Baseline) Has(fingerprint string) bool {
+	return b != nil && b.fingerprints[fingerprint]
+}
+
+// Diff return a report of r which only contains the issues not in the baseline, the baseline issues which
+// are found again as unchanged, and the baseline issues which are resolved: their rule was evaluated
+// successfully on their schema but they were not found again
+func (b *Baseline) Diff(r *Report) *Report {
+	diff := &Report{
+		RunId:      r.RunId,
+		Suppressed: r.Suppressed,
+	}
+	var evaluations = make(map[string]*Evaluation)
+	for _, e := range r.Evaluations {
+		ne := *e
+		ne.Issues = 0
+		diff.Evaluations = append(diff.Evaluations, &ne)
+		evaluations[evaluationKey(e.Rule, e.Schema)] = &ne
+	}
+
+	var seen = make(map[string]bool)
+	for _, issue := range r.Issues {
+		seen[issue.Fingerprint] = true
+		if b.Has(issue.Fingerprint) {
+			diff.Unchanged = append(diff.Unchanged, issue)
+			continue
+		}
+		diff.AddIssue(evaluations[evaluationKey(issue.Rule, issue.Schema)], issue)
+	}
+	for _, issue := range r.Suppressed {
+		seen[issue.Fingerprint] = true
+	}
+
+	for _, issue := range b.Issues {
+		if seen[issue.Fingerprint] {
+			continue
+		}
+		if e, ok := evaluations[evaluationKey(issue.Rule, issue.Schema)]; ok && e.Error == "" {
+			diff.Resolved = append(diff.Resolved, issue)
+		}
+	}
+	return diff
+}
+
+func evaluationKey(rule Rule, schema string) string {
+	return rule.Key() + "\x00" + schema
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselineDiff(t *testing.T) {
+	rule := Rule{Id: "SF010302", Name: "ebs_volume_are_unencrypted", Severity: "Low"}
+	failedRule := Rule{Id: "SF010303", Name: "failed_rule"}
+
+	r := new(Report)
+	e := r.AddEvaluation(rule, "aws_001")
+	r.AddIssue(e, &Issue{Fingerprint: "old", Rule: rule, Schema: "aws_001"})
+	r.AddIssue(e, &Issue{Fingerprint: "new", Rule: rule, Schema: "aws_001"})
+	r.AddSuppressed(e, &Issue{Fingerprint: "suppressed", Rule: rule, Schema: "aws_001"})
+	f := r.AddEvaluation(failedRule, "aws_001")
+	f.Error = "relation does not exist"
+
+	baseline := NewBaseline([]*Issue{
+		{Fingerprint: "old", Rule: rule, Schema: "aws_001"},
+		{Fingerprint: "fixed", Rule: rule, Schema: "aws_001"},
+		{Fingerprint: "suppressed", Rule: rule, Schema: "aws_001"},
+		{Fingerprint: "not_evaluated", Rule: failedRule, Schema: "aws_001"},
+		{Fingerprint: "other_schema", Rule: rule, Schema: "aws_002"},
+	})
+	diff := baseline.Diff(r)
+
+	require.Equal(t, 1, len(diff.Issues))
+	require.Equal(t, "new", diff.Issues[0].Fingerprint)
+	require.Equal(t, 1, diff.Evaluations[0].Issues)
+	require.Equal(t, 2, r.Evaluations[0].Issues)
+	require.Equal(t, 1, len(diff.Resolved))
+	require.Equal(t, "fixed", diff.Resolved[0].Fingerprint)
+	require.Equal(t, 1, len(diff.Unchanged))
+	require.Equal(t, "old", diff.Unchanged[0].Fingerprint)
+	require.Equal(t, 1, len(diff.FailedEvaluations()))
+
+	// the report of a run with a baseline is a complete baseline for the next run
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	require.NoError(t, WriteFile(path, FormatJSON, diff))
+	next, err := ReadBaseline(path)
+	require.NoError(t, err)
+	require.True(t, next.Has("old"))
+	require.True(t, next.Has("new"))
+	require.False(t, next.Has("fixed"))
+}
+
+func TestReadBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	r := testReport()
+	r.Issues[0].Fingerprint = "abc"
+	require.NoError(t, Write(f, FormatJSON, r))
+	require.NoError(t, f.Close())
+
+	baseline, err := ReadBaseline(path)
+	require.NoError(t, err)
+	require.True(t, baseline.Has("abc"))
+	require.False(t, baseline.Has("def"))
+
+	var nilBaseline *Baseline
+	require.False(t, nilBaseline.Has("abc"))
+}
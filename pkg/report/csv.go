@@ -0,0 +1,58 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+var csvHeader = []string{"rule_id", "rule_name", "severity", "title", "provider", "schema", "tags", "src_tables", "labels", "output", "remediation", "rule_path", "status"}
+
+const (
+	// csvStatusOpen is the status of an issue found by the run
+	csvStatusOpen = "open"
+	// csvStatusResolved is the status of an issue of the baseline which was not found again
+	csvStatusResolved = "resolved"
+)
+
+func writeCSV(w io.Writer, r *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	write := func(issue *Issue, status string) error {
+		labels, err := json.Marshal(issue.Labels)
+		if err != nil {
+			return err
+		}
+		record := []string{
+			issue.Rule.Id,
+			issue.Rule.Name,
+			issue.Rule.Severity,
+			issue.Rule.Title,
+			issue.Rule.Provider,
+			issue.Schema,
+			strings.Join(issue.Rule.Tags, ","),
+			strings.Join(issue.SrcTables, ","),
+			string(labels),
+			issue.Output,
+			issue.Rule.Remediation,
+			issue.Rule.Path,
+			status,
+		}
+		return writer.Write(record)
+	}
+	for _, issue := range r.Issues {
+		if err := write(issue, csvStatusOpen); err != nil {
+			return err
+		}
+	}
+	for _, issue := range r.Resolved {
+		if err := write(issue, csvStatusResolved); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
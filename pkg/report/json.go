@@ -0,0 +1,21 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+func writeJSON(w io.Writer, r *Report) error {
+	if r.Evaluations == nil {
+		r.Evaluations = []*Evaluation{}
+	}
+	if r.Issues == nil {
+		r.Issues = []*Issue{}
+	}
+	if r.Suppressed == nil {
+		r.Suppressed = []*Issue{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(r)
+}
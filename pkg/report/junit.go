@@ -0,0 +1,117 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// writeJUnit write every schema as a test suite and every rule evaluated on it as a test case,
+// the resolved issues of a rule are written to the system-out of its test case
+func writeJUnit(w io.Writer, r *Report) error {
+	suites := &junitTestSuites{Name: "selefra"}
+	var suiteMap = make(map[string]*junitTestSuite)
+	var caseMap = make(map[*Evaluation]*junitTestCase)
+	var issuesMap = make(map[string][]*Issue)
+	var resolvedMap = make(map[string][]string)
+
+	for _, issue := range r.Issues {
+		key := issue.Schema + "/" + issue.Rule.Path + "/" + issue.Rule.Name
+		issuesMap[key] = append(issuesMap[key], issue)
+	}
+	for _, issue := range r.Resolved {
+		key := issue.Schema + "/" + issue.Rule.Path + "/" + issue.Rule.Name
+		resolvedMap[key] = append(resolvedMap[key], "resolved: "+issue.Output)
+	}
+
+	for _, e := range r.Evaluations {
+		suite, ok := suiteMap[e.Schema]
+		if !ok {
+			suite = &junitTestSuite{Name: e.Schema}
+			suiteMap[e.Schema] = suite
+			suites.Suites = append(suites.Suites, suite)
+		}
+		testCase := &junitTestCase{
+			Name:      e.Rule.Name,
+			ClassName: e.Schema,
+		}
+		if e.Rule.Id != "" {
+			testCase.Name = e.Rule.Id + " " + e.Rule.Name
+		}
+		caseMap[e] = testCase
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+		suites.Tests++
+
+		if e.Error != "" {
+			testCase.Error = &junitMessage{
+				Message:  e.Error,
+				Type:     "error",
+				Contents: e.Error,
+			}
+			suite.Errors++
+			suites.Errors++
+			continue
+		}
+
+		testCase.SystemOut = strings.Join(resolvedMap[e.Schema+"/"+e.Rule.Path+"/"+e.Rule.Name], "\n")
+		issues := issuesMap[e.Schema+"/"+e.Rule.Path+"/"+e.Rule.Name]
+		if len(issues) == 0 {
+			continue
+		}
+		var outputs []string
+		for _, issue := range issues {
+			outputs = append(outputs, issue.Output)
+		}
+		testCase.Failure = &junitMessage{
+			Message:  fmt.Sprintf("%d issue(s) found, severity: %s", len(issues), e.Rule.Severity),
+			Type:     e.Rule.Severity,
+			Contents: strings.Join(outputs, "\n"),
+		}
+		suite.Failures++
+		suites.Failures++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,162 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+	FormatJUnit = "junit"
+	FormatCSV   = "csv"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported output format, must be one of json, sarif, junit, csv")
+
+// Rule is the metadata of a rule which was evaluated by apply
+type Rule struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Severity    string   `json:"severity"`
+	Provider    string   `json:"provider"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Author      string   `json:"author"`
+	Remediation string   `json:"remediation"`
+	Tags        []string `json:"tags"`
+	Query       string   `json:"query"`
+}
+
+// Key return the id of the rule, the name is used if the rule has no id
+func (r Rule) Key() string {
+	if r.Id != "" {
+		return r.Id
+	}
+	return r.Name
+}
+
+// Issue is a row matched by a rule
+type Issue struct {
+	// Fingerprint identify the issue across runs, it is built from the rule id, schema and key columns of the row
+	Fingerprint string `json:"fingerprint"`
+
+	Rule      Rule                   `json:"rule"`
+	Schema    string                 `json:"schema"`
+	Output    string                 `json:"output"`
+	Labels    map[string]string      `json:"labels"`
+	SrcTables []string               `json:"src_tables"`
+	Row       map[string]interface{} `json:"row"`
+
+	// Suppression is the reason of the suppression which silenced the issue
+	Suppression string `json:"suppression,omitempty"`
+}
+
+// Evaluation record a rule evaluated on a schema, Error is not empty when the rule failed to run
+type Evaluation struct {
+	Rule       Rule   `json:"rule"`
+	Schema     string `json:"schema"`
+	Issues     int    `json:"issues"`
+	Suppressed int    `json:"suppressed"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the result of an apply
+type Report struct {
+	RunId       string        `json:"run_id,omitempty"`
+	Evaluations []*Evaluation `json:"evaluations"`
+	Issues      []*Issue      `json:"issues"`
+	Suppressed  []*Issue      `json:"suppressed"`
+
+	// Resolved are the issues of the baseline which were not found again, only set when apply with a baseline
+	Resolved []*Issue `json:"resolved,omitempty"`
+	// Unchanged are the issues of the baseline which were found again, only set when apply with a baseline,
+	// with Issues they are all the issues of the run so that the report can be used as the next baseline
+	Unchanged []*Issue `json:"unchanged,omitempty"`
+}
+
+// AddEvaluation add a rule evaluation to the report and return it, so that issues can be counted
+func (r *Report) AddEvaluation(rule Rule, schema string) *Evaluation {
+	e := &Evaluation{
+		Rule:   rule,
+		Schema: schema,
+	}
+	r.Evaluations = append(r.Evaluations, e)
+	return e
+}
+
+// AddIssue add an issue to the report and count it in its evaluation
+func (r *Report) AddIssue(e *Evaluation, issue *Issue) {
+	if e != nil {
+		e.Issues++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// AddSuppressed add an issue silenced by a suppression to the report and count it in its evaluation
+func (r *Report) AddSuppressed(e *Evaluation, issue *Issue) {
+	if e != nil {
+		e.Suppressed++
+	}
+	r.Suppressed = append(r.Suppressed, issue)
+}
+
+// FormatByPath guess the output format by the extension of path, default is json
+func FormatByPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sarif":
+		return FormatSARIF
+	case ".xml":
+		return FormatJUnit
+	case ".csv":
+		return FormatCSV
+	default:
+		return FormatJSON
+	}
+}
+
+// Write write the report to w in the given format
+func Write(w io.Writer, format string, r *Report) error {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return writeJSON(w, r)
+	case FormatSARIF:
+		return writeSARIF(w, r)
+	case FormatJUnit:
+		return writeJUnit(w, r)
+	case FormatCSV:
+		return writeCSV(w, r)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// WriteFile write the report to the file at path
+func WriteFile(path string, format string, r *Report) error {
+	if path == "" {
+		return errors.New("the path of the report is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Write(f, format, r)
+}
+
+// CheckFormat return an error if format is not supported
+func CheckFormat(format string) error {
+	switch strings.ToLower(format) {
+	case FormatJSON, FormatSARIF, FormatJUnit, FormatCSV:
+		return nil
+	default:
+		return ErrUnsupportedFormat
+	}
+}
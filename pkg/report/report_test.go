@@ -0,0 +1,124 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	r := new(Report)
+	rule := Rule{
+		Id:       "SF010302",
+		Name:     "ebs_volume_are_unencrypted",
+		Path:     "rules/iam_mfa.yaml",
+		Severity: "Low",
+		Title:    "EBS volume are unencrypted",
+		Tags:     []string{"Security"},
+	}
+	e := r.AddEvaluation(rule, "aws_001_aws_01")
+	r.AddIssue(e, &Issue{
+		Rule:      rule,
+		Schema:    "aws_001_aws_01",
+		Output:    "EBS volume are unencrypted, EBS id: vol-1",
+		Labels:    map[string]string{"author": "Selefra"},
+		SrcTables: []string{"aws_ec2_ebs_volumes"},
+	})
+	r.AddEvaluation(Rule{Name: "passed_rule"}, "aws_001_aws_01")
+	return r
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatJSON, testReport()))
+
+	var r Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &r))
+	require.Equal(t, 1, len(r.Issues))
+	require.Equal(t, 2, len(r.Evaluations))
+	require.Equal(t, 1, r.Evaluations[0].Issues)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatSARIF, testReport()))
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, sarifVersion, log.Version)
+	require.Equal(t, 2, len(log.Runs[0].Tool.Driver.Rules))
+	require.Equal(t, 1, len(log.Runs[0].Results))
+	require.Equal(t, "SF010302", log.Runs[0].Results[0].RuleId)
+	require.Equal(t, "note", log.Runs[0].Results[0].Level)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatJUnit, testReport()))
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 2, suites.Tests)
+	require.Equal(t, 1, suites.Failures)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatCSV, testReport()))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "SF010302", records[1][0])
+}
+
+func TestWriteResolved(t *testing.T) {
+	r := testReport()
+	resolved := *r.Issues[0]
+	resolved.Output = "EBS volume are unencrypted, EBS id: vol-2"
+	r.Resolved = []*Issue{&resolved}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatSARIF, r))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, 2, len(log.Runs[0].Results))
+	require.Equal(t, "", log.Runs[0].Results[0].BaselineState)
+	require.Equal(t, sarifBaselineAbsent, log.Runs[0].Results[1].BaselineState)
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, FormatJUnit, r))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 1, suites.Failures)
+	require.Equal(t, "resolved: "+resolved.Output, suites.Suites[0].TestCases[0].SystemOut)
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, FormatCSV, r))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, csvStatusOpen, records[1][len(csvHeader)-1])
+	require.Equal(t, csvStatusResolved, records[2][len(csvHeader)-1])
+}
+
+func TestFormatByPath(t *testing.T) {
+	require.Equal(t, FormatSARIF, FormatByPath("result.sarif"))
+	require.Equal(t, FormatJUnit, FormatByPath("result.xml"))
+	require.Equal(t, FormatCSV, FormatByPath("result.csv"))
+	require.Equal(t, FormatJSON, FormatByPath("result"))
+	require.Error(t, Write(&bytes.Buffer{}, "yaml", testReport()))
+}
+
+func TestCountIssuesAtLeast(t *testing.T) {
+	r := testReport()
+	require.Equal(t, 1, r.CountIssuesAtLeast("low"))
+	require.Equal(t, 1, r.CountIssuesAtLeast("Informational"))
+	require.Equal(t, 0, r.CountIssuesAtLeast("HIGH"))
+	require.Error(t, CheckSeverity("urgent"))
+	require.Equal(t, 0, len(r.FailedEvaluations()))
+}
@@ -0,0 +1,202 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string              `json:"id"`
+	Name             string              `json:"name"`
+	ShortDescription sarifMessage        `json:"shortDescription"`
+	FullDescription  sarifMessage        `json:"fullDescription"`
+	Help             sarifMessage        `json:"help"`
+	Properties       sarifRuleProperties `json:"properties"`
+}
+
+type sarifRuleProperties struct {
+	Tags     []string `json:"tags"`
+	Severity string   `json:"severity"`
+	Provider string   `json:"provider"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId        string                `json:"ruleId"`
+	RuleIndex     int                   `json:"ruleIndex"`
+	Level         string                `json:"level"`
+	Message       sarifMessage          `json:"message"`
+	Locations     []sarifLocation       `json:"locations"`
+	Suppressions  []sarifSuppression    `json:"suppressions,omitempty"`
+	BaselineState string                `json:"baselineState,omitempty"`
+	Properties    sarifResultProperties `json:"properties"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+}
+
+type sarifResultProperties struct {
+	Schema    string            `json:"schema"`
+	Labels    map[string]string `json:"labels"`
+	SrcTables []string          `json:"srcTables"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+// sarifLevel convert rule severity to sarif level
+func sarifLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifBaselineAbsent is the baseline state of a result which is in the baseline but not found again
+const sarifBaselineAbsent = "absent"
+
+func writeSARIF(w io.Writer, r *Report) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "selefra",
+				InformationUri: "https://selefra.io",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	var ruleIndex = make(map[string]int)
+	addRule := func(rule Rule) int {
+		id := rule.Key()
+		if index, ok := ruleIndex[id]; ok {
+			return index
+		}
+		help := rule.Remediation
+		if help == "" {
+			help = rule.Description
+		}
+		tags := rule.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			Id:               id,
+			Name:             rule.Name,
+			ShortDescription: sarifMessage{Text: rule.Title},
+			FullDescription:  sarifMessage{Text: rule.Description},
+			Help:             sarifMessage{Text: help},
+			Properties: sarifRuleProperties{
+				Tags:     tags,
+				Severity: rule.Severity,
+				Provider: rule.Provider,
+			},
+		})
+		ruleIndex[id] = len(run.Tool.Driver.Rules) - 1
+		return ruleIndex[id]
+	}
+
+	for _, e := range r.Evaluations {
+		addRule(e.Rule)
+	}
+
+	addResult := func(issue *Issue, baselineState string) {
+		index := addRule(issue.Rule)
+		srcTables := issue.SrcTables
+		if srcTables == nil {
+			srcTables = []string{}
+		}
+		var suppressions []sarifSuppression
+		if issue.Suppression != "" {
+			suppressions = append(suppressions, sarifSuppression{
+				Kind:          "external",
+				Justification: issue.Suppression,
+			})
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleId:    issue.Rule.Key(),
+			RuleIndex: index,
+			Level:     sarifLevel(issue.Rule.Severity),
+			Message:   sarifMessage{Text: issue.Output},
+			Locations: []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{Uri: filepath.ToSlash(issue.Rule.Path)},
+					},
+				},
+			},
+			Suppressions:  suppressions,
+			BaselineState: baselineState,
+			Properties: sarifResultProperties{
+				Schema:    issue.Schema,
+				Labels:    issue.Labels,
+				SrcTables: srcTables,
+			},
+		})
+	}
+
+	for _, issue := range r.Issues {
+		addResult(issue, "")
+	}
+	for _, issue := range r.Suppressed {
+		addResult(issue, "")
+	}
+	for _, issue := range r.Resolved {
+		addResult(issue, sarifBaselineAbsent)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// severityLevels sort the rule severities from low to high, severities not in it are treated as the lowest
+var severityLevels = map[string]int{
+	"informational": 1,
+	"info":          1,
+	"low":           2,
+	"medium":        3,
+	"high":          4,
+	"critical":      5,
+}
+
+// SeverityLevel return the level of severity, 0 means an unknown severity
+func SeverityLevel(severity string) int {
+	return severityLevels[strings.ToLower(strings.TrimSpace(severity))]
+}
+
+// CheckSeverity return an error if severity is unknown
+func CheckSeverity(severity string) error {
+	if SeverityLevel(severity) == 0 {
+		return fmt.Errorf("unknown severity %s, must be one of informational, low, medium, high, critical", severity)
+	}
+	return nil
+}
+
+// CountIssuesAtLeast count the issues whose severity is at or above severity
+func (r *Report) CountIssuesAtLeast(severity string) int {
+	level := SeverityLevel(severity)
+	count := 0
+	for _, issue := range r.Issues {
+		if SeverityLevel(issue.Rule.Severity) >= level {
+			count++
+		}
+	}
+	return count
+}
+
+// FailedEvaluations return the evaluations which failed to run
+func (r *Report) FailedEvaluations() []*Evaluation {
+	var failed []*Evaluation
+	for _, e := range r.Evaluations {
+		if e.Error != "" {
+			failed = append(failed, e)
+		}
+	}
+	return failed
+}
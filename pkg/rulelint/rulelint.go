@@ -0,0 +1,500 @@
+package rulelint
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/blastrain/vitess-sqlparser/sqlparser"
+	"github.com/selefra/selefra/config"
+	"github.com/selefra/selefra/pkg/utils"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Issue is a problem found in a rule, Line is 0 when the location is unknown
+type Issue struct {
+	Path    string
+	Line    int
+	Rule    string
+	Level   string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: rule %s: %s", i.Path, i.Line, i.Rule, i.Message)
+}
+
+// Lint check rules, the tables and columns referenced by queries are only checked when tables is not empty,
+// tables is keyed by lower case table name and then by lower case column name, identifiers are matched
+// case-insensitively like the unquoted identifiers of postgres.
+// the queries are parsed by a MySQL parser, so the postgres syntax it does not know, such as :: casts, WITH queries
+// and set-returning functions in FROM clause, is reported as a warning and the query is not checked
+func Lint(rules []config.Rule, variablesMap map[string]interface{}, tables map[string]map[string]bool) []Issue {
+	var issues []Issue
+	var lines = make(map[string]map[string]map[string]int)
+	for _, rule := range rules {
+		path := rule.LocalPath()
+		if path == "" {
+			path = rule.Path
+		} else if _, ok := lines[path]; !ok {
+			lines[path] = ruleLines(path)
+		}
+		ruleLine := lines[path][rule.Name]
+		add := func(key, level, format string, a ...interface{}) {
+			line, ok := ruleLine[key]
+			if !ok {
+				line = ruleLine[""]
+			}
+			issues = append(issues, Issue{
+				Path:    path,
+				Line:    line,
+				Rule:    rule.Name,
+				Level:   level,
+				Message: fmt.Sprintf(format, a...),
+			})
+		}
+
+		fields, err := templateFields(rule.Query)
+		if err != nil {
+			add("query", LevelError, "invalid query template: %s", err.Error())
+			continue
+		}
+		for _, field := range fields {
+			if _, ok := variablesMap[field]; !ok {
+				add("query", LevelError, "variable %s used by query is not declared", field)
+			}
+		}
+		queryStr, err := renderTemplate(rule.Query, variablesMap)
+		if err != nil {
+			add("query", LevelError, "invalid query template: %s", err.Error())
+			continue
+		}
+
+		// the parser is of MySQL, a query which is parsed differently from postgres is not checked
+		if token := misparsedToken(queryStr); token != "" {
+			add("query", LevelWarning, "query uses %s which is not supported by the parser, table and column checks are skipped", token)
+			continue
+		}
+		stmt, err := sqlparser.Parse(queryStr)
+		if err != nil {
+			add("query", LevelWarning, "query can not be parsed, table and column checks are skipped: %s", err.Error())
+			continue
+		}
+		result := analyzeQuery(stmt, tables)
+		for _, message := range result.errors {
+			add("query", LevelError, "%s", message)
+		}
+		if !result.complete {
+			continue
+		}
+
+		fields, err = templateFields(rule.Output)
+		if err != nil {
+			add("output", LevelError, "invalid output template: %s", err.Error())
+		}
+		for _, field := range fields {
+			if !result.columns[field] {
+				add("output", LevelError, "column %s used by output is not returned by query", field)
+			}
+		}
+		var labelKeys []string
+		for key := range rule.Labels {
+			labelKeys = append(labelKeys, key)
+		}
+		sort.Strings(labelKeys)
+		for _, key := range labelKeys {
+			for _, label := range labelTemplates(rule.Labels[key]) {
+				fields, err := templateFields(label)
+				if err != nil {
+					add("labels", LevelError, "invalid template of label %s: %s", key, err.Error())
+					continue
+				}
+				for _, field := range fields {
+					if !result.columns[field] {
+						add("labels", LevelError, "column %s used by label %s is not returned by query", field, key)
+					}
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// misparsedToken return the postgres token of query which the MySQL parser accepts with another meaning,
+// such as the jsonb operators starting with # which start a comment in MySQL, quoted strings and comments are skipped
+func misparsedToken(query string) string {
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '\'':
+			for i++; i < len(query) && query[i] != '\''; i++ {
+			}
+		case '-':
+			if i+1 < len(query) && query[i+1] == '-' {
+				for ; i < len(query) && query[i] != '\n'; i++ {
+				}
+			}
+		case '/':
+			if i+1 < len(query) && query[i+1] == '*' {
+				end := strings.Index(query[i+2:], "*/")
+				if end < 0 {
+					return ""
+				}
+				i += end + 3
+			}
+		case '#':
+			end := i + 1
+			for end < len(query) && strings.ContainsRune("#>-", rune(query[end])) {
+				end++
+			}
+			return "operator " + query[i:end]
+		}
+	}
+	return ""
+}
+
+// ruleLines return the lines of rules in the rule file, keyed by rule name and then by the key of rule,
+// the line of the rule itself is keyed by empty string
+func ruleLines(path string) map[string]map[string]int {
+	var lines = make(map[string]map[string]int)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return lines
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil || len(node.Content) == 0 {
+		return lines
+	}
+	root := node.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "rules" {
+			continue
+		}
+		for _, ruleNode := range root.Content[i+1].Content {
+			var keys = map[string]int{"": ruleNode.Line}
+			var name string
+			for j := 0; j+1 < len(ruleNode.Content); j += 2 {
+				keys[ruleNode.Content[j].Value] = ruleNode.Content[j].Line
+				if ruleNode.Content[j].Value == "name" {
+					name = ruleNode.Content[j+1].Value
+				}
+			}
+			lines[name] = keys
+		}
+	}
+	return lines
+}
+
+// labelTemplates return the templates of a label value, which is a string or a list of strings
+func labelTemplates(label interface{}) []string {
+	switch v := label.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var templates []string
+		for _, item := range v {
+			templates = append(templates, utils.Strava(item))
+		}
+		return templates
+	}
+	return nil
+}
+
+func renderTemplate(text string, params map[string]interface{}) (string, error) {
+	t, err := template.New("temp").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, params); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// templateFields return the top level fields referenced by the template, such as id of {{.id}},
+// fields inside range and with blocks are skipped because the dot is changed there
+func templateFields(text string) ([]string, error) {
+	t, err := template.New("temp").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	var seen = make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+		case *parse.WithNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if len(n.Ident) > 0 && !seen[n.Ident[0]] {
+				seen[n.Ident[0]] = true
+				fields = append(fields, n.Ident[0])
+			}
+		}
+	}
+	if t.Tree != nil {
+		walk(t.Tree.Root)
+	}
+	return fields, nil
+}
+
+// querySource is a table or derived table in the FROM clause, columns is nil when they are unknown
+type querySource struct {
+	name    string
+	columns map[string]bool
+}
+
+// queryResult is the result of analyzing a query, columns are the columns returned by the query and
+// complete is false when some returned columns can not be known
+type queryResult struct {
+	errors   []string
+	columns  map[string]bool
+	complete bool
+}
+
+// analyzeQuery check the tables and columns referenced by stmt, tables and columns are not checked when tables is empty
+func analyzeQuery(stmt sqlparser.Statement, tables map[string]map[string]bool) *queryResult {
+	a := &queryAnalyzer{tables: tables}
+	columns, complete := a.selectStatement(stmt, nil)
+	return &queryResult{
+		errors:   a.errors,
+		columns:  columns,
+		complete: complete,
+	}
+}
+
+type queryAnalyzer struct {
+	tables map[string]map[string]bool
+	errors []string
+}
+
+func (a *queryAnalyzer) errorf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	for _, e := range a.errors {
+		if e == message {
+			return
+		}
+	}
+	a.errors = append(a.errors, message)
+}
+
+// selectStatement analyze a select statement in the scope of outer sources, return the columns it returns
+func (a *queryAnalyzer) selectStatement(stmt sqlparser.SQLNode, outer []querySource) (map[string]bool, bool) {
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		return a.selectQuery(s, outer)
+	case *sqlparser.Union:
+		columns, complete := a.selectStatement(s.Left, outer)
+		a.selectStatement(s.Right, outer)
+		return columns, complete
+	case *sqlparser.ParenSelect:
+		return a.selectStatement(s.Select, outer)
+	}
+	return nil, false
+}
+
+func (a *queryAnalyzer) selectQuery(sel *sqlparser.Select, outer []querySource) (map[string]bool, bool) {
+	var sources []querySource
+	for _, expr := range sel.From {
+		sources = a.tableExpr(expr, sources, outer)
+	}
+	scope := append(append([]querySource{}, sources...), outer...)
+
+	var aliases = make(map[string]bool)
+	var columns = make(map[string]bool)
+	complete := true
+	for _, expr := range sel.SelectExprs {
+		switch e := expr.(type) {
+		case *sqlparser.StarExpr:
+			if e.TableName.IsEmpty() {
+				for _, source := range sources {
+					if source.columns == nil {
+						complete = false
+					}
+					for column := range source.columns {
+						columns[column] = true
+					}
+				}
+				continue
+			}
+			source, ok := findSource(scope, e.TableName.Name.String())
+			if !ok || source.columns == nil {
+				complete = false
+				continue
+			}
+			for column := range source.columns {
+				columns[column] = true
+			}
+		case *sqlparser.AliasedExpr:
+			if !e.As.IsEmpty() {
+				columns[e.As.Lowered()] = true
+				aliases[e.As.Lowered()] = true
+			} else if col, ok := e.Expr.(*sqlparser.ColName); ok {
+				columns[col.Name.Lowered()] = true
+			} else {
+				complete = false
+			}
+		default:
+			complete = false
+		}
+	}
+
+	var nodes []sqlparser.SQLNode
+	nodes = append(nodes, sel.SelectExprs, sel.GroupBy, sel.OrderBy)
+	if sel.Where != nil {
+		nodes = append(nodes, sel.Where)
+	}
+	if sel.Having != nil {
+		nodes = append(nodes, sel.Having)
+	}
+	for _, expr := range sel.From {
+		nodes = append(nodes, joinConditions(expr)...)
+	}
+	a.columns(nodes, scope, aliases)
+	return columns, complete
+}
+
+// tableExpr add the sources of a table expression of FROM clause
+func (a *queryAnalyzer) tableExpr(expr sqlparser.TableExpr, sources []querySource, outer []querySource) []querySource {
+	switch e := expr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		switch t := e.Expr.(type) {
+		case sqlparser.TableName:
+			name := t.Name.String()
+			source := querySource{name: name}
+			if !e.As.IsEmpty() {
+				source.name = e.As.String()
+			}
+			if len(a.tables) > 0 {
+				if columns, ok := a.tables[strings.ToLower(name)]; ok {
+					source.columns = columns
+				} else {
+					a.errorf("table %s does not exist", name)
+				}
+			}
+			return append(sources, source)
+		case *sqlparser.Subquery:
+			columns, complete := a.selectStatement(t.Select, outer)
+			source := querySource{name: e.As.String()}
+			if complete {
+				source.columns = columns
+			}
+			return append(sources, source)
+		}
+	case *sqlparser.JoinTableExpr:
+		sources = a.tableExpr(e.LeftExpr, sources, outer)
+		return a.tableExpr(e.RightExpr, sources, outer)
+	case *sqlparser.ParenTableExpr:
+		for _, child := range e.Exprs {
+			sources = a.tableExpr(child, sources, outer)
+		}
+	}
+	return sources
+}
+
+// joinConditions return the ON conditions of join expressions
+func joinConditions(expr sqlparser.TableExpr) []sqlparser.SQLNode {
+	switch e := expr.(type) {
+	case *sqlparser.JoinTableExpr:
+		nodes := append(joinConditions(e.LeftExpr), joinConditions(e.RightExpr)...)
+		if e.On != nil {
+			nodes = append(nodes, e.On)
+		}
+		return nodes
+	case *sqlparser.ParenTableExpr:
+		var nodes []sqlparser.SQLNode
+		for _, child := range e.Exprs {
+			nodes = append(nodes, joinConditions(child)...)
+		}
+		return nodes
+	}
+	return nil
+}
+
+// columns check the columns referenced by nodes, subqueries are analyzed in their own scope
+func (a *queryAnalyzer) columns(nodes []sqlparser.SQLNode, scope []querySource, aliases map[string]bool) {
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			a.selectStatement(n.Select, scope)
+			return false, nil
+		case *sqlparser.ColName:
+			a.column(n, scope, aliases)
+			return false, nil
+		}
+		return true, nil
+	}, nodes...)
+}
+
+func (a *queryAnalyzer) column(col *sqlparser.ColName, scope []querySource, aliases map[string]bool) {
+	if len(a.tables) == 0 {
+		return
+	}
+	name := col.Name.Lowered()
+	if !col.Qualifier.IsEmpty() {
+		qualifier := col.Qualifier.Name.String()
+		source, ok := findSource(scope, qualifier)
+		if !ok {
+			a.errorf("table or alias %s of column %s.%s is not in FROM clause", qualifier, qualifier, name)
+			return
+		}
+		if source.columns != nil && !source.columns[name] {
+			a.errorf("column %s does not exist in %s", name, qualifier)
+		}
+		return
+	}
+	if aliases[name] {
+		return
+	}
+	for _, source := range scope {
+		if source.columns == nil || source.columns[name] {
+			return
+		}
+	}
+	a.errorf("column %s does not exist in any table of FROM clause", name)
+}
+
+func findSource(scope []querySource, name string) (querySource, bool) {
+	for _, source := range scope {
+		if strings.EqualFold(source.name, name) {
+			return source, true
+		}
+	}
+	return querySource{}, false
+}
@@ -0,0 +1,110 @@
+package rulelint
+
+import (
+	"github.com/selefra/selefra/config"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+var testTables = map[string]map[string]bool{
+	"aws_ec2_ebs_volumes": {"id": true, "encrypted": true, "availability_zone": true, "account_id": true},
+	"aws_ec2_instances":   {"instance_id": true, "volume_id": true},
+}
+
+func testRule(query, output string, labels map[string]interface{}) config.Rule {
+	return config.Rule{Name: "test_rule", Query: query, Output: output, Labels: labels}
+}
+
+func messages(issues []Issue) []string {
+	var m []string
+	for _, issue := range issues {
+		m = append(m, issue.Level+": "+issue.Message)
+	}
+	return m
+}
+
+func TestLint(t *testing.T) {
+	variables := map[string]interface{}{"zone": "us-east-1a"}
+	cases := []struct {
+		rule   config.Rule
+		expect []string
+	}{
+		{
+			rule: testRule("SELECT * FROM aws_ec2_ebs_volumes WHERE encrypted = FALSE AND availability_zone = '{{.zone}}'", "EBS id: {{.id}}", map[string]interface{}{"tag": []interface{}{"Security"}, "zone": "{{.availability_zone}}"}),
+		},
+		{
+			rule:   testRule("SELECT * FROM aws_ec2_volumes", "EBS id: {{.id}}", nil),
+			expect: []string{"error: table aws_ec2_volumes does not exist"},
+		},
+		{
+			rule: testRule("SELECT id FROM aws_ec2_ebs_volumes WHERE encrypt = FALSE", "{{.id}} {{.availability_zone}}", map[string]interface{}{"account": "{{.account_id}}"}),
+			expect: []string{
+				"error: column encrypt does not exist in any table of FROM clause",
+				"error: column availability_zone used by output is not returned by query",
+				"error: column account_id used by label account is not returned by query",
+			},
+		},
+		{
+			rule: testRule("SELECT v.id AS volume, i.instance_id FROM aws_ec2_ebs_volumes v JOIN aws_ec2_instances i ON i.volume_id = v.id WHERE v.zone = 'a' ORDER BY volume", "{{.volume}} {{.instance_id}}", nil),
+			expect: []string{
+				"error: column zone does not exist in v",
+			},
+		},
+		{
+			rule: testRule("SELECT id FROM aws_ec2_ebs_volumes WHERE id IN (SELECT volume_id FROM aws_ec2_instances WHERE instance_id = id)", "{{.id}}", nil),
+		},
+		{
+			rule:   testRule("SELECT * FROM aws_ec2_ebs_volumes WHERE availability_zone = '{{.region}}'", "{{.id}}", nil),
+			expect: []string{"error: variable region used by query is not declared"},
+		},
+		{
+			rule:   testRule("SELECT * FROM aws_ec2_ebs_volumes WHERE", "{{.id}}", nil),
+			expect: []string{"warning: query can not be parsed, table and column checks are skipped: syntax error at position 41"},
+		},
+		{
+			rule:   testRule("SELECT id::text AS id FROM aws_ec2_ebs_volumes WHERE availability_zone ILIKE 'us-%'", "{{.id}}", nil),
+			expect: []string{"warning: query can not be parsed, table and column checks are skipped: syntax error at position 16 near '::text'"},
+		},
+		{
+			rule:   testRule("SELECT id FROM aws_ec2_ebs_volumes WHERE tags #>> '{env}' = 'prod'", "{{.id}}", nil),
+			expect: []string{"warning: query uses operator #>> which is not supported by the parser, table and column checks are skipped"},
+		},
+		{
+			rule: testRule("SELECT id FROM aws_ec2_ebs_volumes WHERE availability_zone <> '#1' -- # is not an operator here", "{{.id}}", nil),
+		},
+		{
+			rule: testRule("SELECT V.ID, Encrypted AS Enc FROM AWS_EC2_EBS_VOLUMES v WHERE v.Availability_Zone = 'a' ORDER BY ENC", "{{.id}} {{.enc}}", nil),
+		},
+		{
+			rule:   testRule("WITH v AS (SELECT id FROM aws_ec2_ebs_volumes) SELECT id FROM v", "{{.id}}", nil),
+			expect: []string{"warning: query can not be parsed, table and column checks are skipped: syntax error at position 5 near 'with'"},
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, messages(Lint([]config.Rule{c.rule}, variables, testTables)), c.rule.Query)
+	}
+}
+
+func TestLintWithoutTables(t *testing.T) {
+	rules := []config.Rule{
+		testRule("SELECT * FROM aws_ec2_volumes", "{{.id}}", nil),
+		testRule("SELECT id FROM aws_ec2_volumes", "{{.id}} {{.name}}", nil),
+	}
+	require.Equal(t, []string{"error: column name used by output is not returned by query"}, messages(Lint(rules, nil, nil)))
+}
+
+func TestLintLocation(t *testing.T) {
+	path, err := filepath.Abs("../../tests/workspace/offline/rules/iam_mfa.yaml")
+	require.NoError(t, err)
+	rule := config.Rule{
+		Path:   path,
+		Name:   "ebs_volume_are_unencrypted",
+		Query:  "SELECT * FROM aws_ec2_volumes",
+		Output: "{{.id}}",
+	}
+	issues := Lint([]config.Rule{rule}, nil, testTables)
+	require.Equal(t, 1, len(issues))
+	require.Equal(t, path, issues[0].Path)
+	require.Equal(t, 3, issues[0].Line)
+}
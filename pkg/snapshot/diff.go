@@ -0,0 +1,300 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/storage"
+	"github.com/selefra/selefra/pkg/utils"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// defaultKeyColumns identify a resource when the table has no usable primary key
+var defaultKeyColumns = []string{"arn", "id"}
+
+// scopeColumns make an id unique when the same id may be used by the resources of other accounts or regions
+var scopeColumns = []string{"account_id", "region"}
+
+// ColumnChange is a column of a resource whose value is changed
+type ColumnChange struct {
+	Column string      `json:"column"`
+	From   interface{} `json:"from"`
+	To     interface{} `json:"to"`
+}
+
+// ResourceChange is a resource added, removed or modified between two fetches
+type ResourceChange struct {
+	Change  string                 `json:"change"`
+	Key     map[string]interface{} `json:"key"`
+	Columns []ColumnChange         `json:"columns,omitempty"`
+}
+
+// TableDiff is the resource changes of a table
+type TableDiff struct {
+	Table    string `json:"table"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Modified int    `json:"modified"`
+	// Duplicates is the count of rows whose key is the same as another row of the same fetch,
+	// only the last of them is compared
+	Duplicates int               `json:"duplicates,omitempty"`
+	Changes    []*ResourceChange `json:"changes"`
+}
+
+// Empty return true if no resource of the table is changed
+func (d *TableDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// generatedColumn return true if the column is generated by every fetch, such as the random ids linking
+// a row to its parent, they change even if the resource does not
+func generatedColumn(column string) bool {
+	return column == "selefra_id" || strings.HasSuffix(column, "_selefra_id")
+}
+
+// DiffKeyColumns return the columns which identify the resources of a table, the primary key takes precedence,
+// then arn or id with account_id and region if the table has them, all the columns are used if the table has none of them
+func DiffKeyColumns(primaryKeys []string, columns []string) []string {
+	var keys []string
+	for _, column := range primaryKeys {
+		if !generatedColumn(column) {
+			keys = append(keys, column)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+	var columnSet = make(map[string]bool)
+	for _, column := range columns {
+		columnSet[column] = true
+	}
+	for _, column := range defaultKeyColumns {
+		if !columnSet[column] {
+			continue
+		}
+		keys = []string{column}
+		if column == "id" {
+			for _, scope := range scopeColumns {
+				if columnSet[scope] {
+					keys = append(keys, scope)
+				}
+			}
+		}
+		return keys
+	}
+	for _, column := range columns {
+		if !generatedColumn(column) {
+			keys = append(keys, column)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiffRows compare the rows of a table fetched at two times, resources are matched by keyColumns,
+// the rows of a fetch which have the same key are counted as duplicates
+func DiffRows(table string, keyColumns []string, from, to []map[string]interface{}) *TableDiff {
+	d := &TableDiff{Table: table}
+	var fromRows = make(map[string]map[string]interface{})
+	var fromKeys []string
+	for _, row := range from {
+		k := rowKey(keyColumns, row)
+		if _, ok := fromRows[k]; !ok {
+			fromKeys = append(fromKeys, k)
+		} else {
+			d.Duplicates++
+		}
+		fromRows[k] = row
+	}
+
+	var seen = make(map[string]bool)
+	for _, row := range to {
+		k := rowKey(keyColumns, row)
+		if seen[k] {
+			d.Duplicates++
+			continue
+		}
+		seen[k] = true
+		old, ok := fromRows[k]
+		if !ok {
+			d.Added++
+			d.Changes = append(d.Changes, &ResourceChange{Change: ChangeAdded, Key: keyOf(keyColumns, row)})
+			continue
+		}
+		if columns := columnChanges(old, row); len(columns) > 0 {
+			d.Modified++
+			d.Changes = append(d.Changes, &ResourceChange{Change: ChangeModified, Key: keyOf(keyColumns, row), Columns: columns})
+		}
+	}
+	for _, k := range fromKeys {
+		if seen[k] {
+			continue
+		}
+		d.Removed++
+		d.Changes = append(d.Changes, &ResourceChange{Change: ChangeRemoved, Key: keyOf(keyColumns, fromRows[k])})
+	}
+	return d
+}
+
+func rowKey(keyColumns []string, row map[string]interface{}) string {
+	var values []string
+	for _, column := range keyColumns {
+		values = append(values, utils.Strava(row[column]))
+	}
+	return strings.Join(values, "\x00")
+}
+
+func keyOf(keyColumns []string, row map[string]interface{}) map[string]interface{} {
+	var key = make(map[string]interface{})
+	for _, column := range keyColumns {
+		key[column] = row[column]
+	}
+	return key
+}
+
+// columnChanges return the changed columns of a resource, a column added or dropped by a provider upgrade counts as changed
+func columnChanges(from, to map[string]interface{}) []ColumnChange {
+	var columnSet = make(map[string]bool)
+	for column := range from {
+		columnSet[column] = true
+	}
+	for column := range to {
+		columnSet[column] = true
+	}
+	var columns []string
+	for column := range columnSet {
+		if !generatedColumn(column) {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	var changes []ColumnChange
+	for _, column := range columns {
+		if utils.Strava(from[column]) != utils.Strava(to[column]) {
+			changes = append(changes, ColumnChange{Column: column, From: from[column], To: to[column]})
+		}
+	}
+	return changes
+}
+
+// Diff compare the tables of fromSchema and toSchema, all the tables of both schemas are compared if tables is empty,
+// the tables in neither schema are skipped
+func Diff(ctx context.Context, sto storage.Storage, fromSchema, toSchema string, tables []string) ([]*TableDiff, error) {
+	fromTables, err := schemaTables(ctx, sto, fromSchema)
+	if err != nil {
+		return nil, err
+	}
+	toTables, err := schemaTables(ctx, sto, toSchema)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		var tableSet = make(map[string]bool)
+		for table := range fromTables {
+			tableSet[table] = true
+		}
+		for table := range toTables {
+			tableSet[table] = true
+		}
+		for table := range tableSet {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+	}
+
+	var diffs []*TableDiff
+	for _, table := range tables {
+		if !fromTables[table] && !toTables[table] {
+			continue
+		}
+		var from, to []map[string]interface{}
+		var columns []string
+		if fromTables[table] {
+			if from, columns, err = tableRows(ctx, sto, fromSchema, table); err != nil {
+				return nil, err
+			}
+		}
+		primaryKeySchema := fromSchema
+		if toTables[table] {
+			if to, columns, err = tableRows(ctx, sto, toSchema, table); err != nil {
+				return nil, err
+			}
+			primaryKeySchema = toSchema
+		}
+		primaryKeys, err := tablePrimaryKeys(ctx, sto, primaryKeySchema, table)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, DiffRows(table, DiffKeyColumns(primaryKeys, columns), from, to))
+	}
+	return diffs, nil
+}
+
+// schemaTables return the tables of provider in schema, the tables of selefra itself such as selefra_meta_kv are skipped
+func schemaTables(ctx context.Context, sto storage.Storage, schema string) (map[string]bool, error) {
+	rows, err := queryMatrix(ctx, sto, `SELECT table_name FROM information_schema.tables WHERE table_schema = $1 AND table_name NOT LIKE 'selefra\_%'`, schema)
+	if err != nil {
+		return nil, err
+	}
+	var tables = make(map[string]bool)
+	for _, row := range rows {
+		tables[utils.Strava(row[0])] = true
+	}
+	return tables, nil
+}
+
+func tablePrimaryKeys(ctx context.Context, sto storage.Storage, schema, table string) ([]string, error) {
+	rows, err := queryMatrix(ctx, sto, `SELECT a.attname FROM pg_index i
+	JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+	WHERE i.indrelid = format('%I.%I', $1::text, $2::text)::regclass AND i.indisprimary`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, row := range rows {
+		columns = append(columns, utils.Strava(row[0]))
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+func tableRows(ctx context.Context, sto storage.Storage, schema, table string) ([]map[string]interface{}, []string, error) {
+	res, diag := sto.Query(ctx, fmt.Sprintf("SELECT * FROM %s.%s", schema, table))
+	if diag != nil && diag.HasError() {
+		return nil, nil, errors.New(diag.ToString())
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, nil, errors.New(diag.ToString())
+	}
+	columns := rows.GetColumnNames()
+	var maps []map[string]interface{}
+	for _, row := range rows.GetMatrix() {
+		var m = make(map[string]interface{})
+		for i, value := range row {
+			m[columns[i]] = value
+		}
+		maps = append(maps, m)
+	}
+	return maps, columns, nil
+}
+
+func queryMatrix(ctx context.Context, sto storage.Storage, sql string, args ...any) ([][]interface{}, error) {
+	res, diag := sto.Query(ctx, sql, args...)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return nil, errors.New(diag.ToString())
+	}
+	return rows.GetMatrix(), nil
+}
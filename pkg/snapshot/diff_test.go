@@ -0,0 +1,56 @@
+package snapshot
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDiffKeyColumns(t *testing.T) {
+	require.Equal(t, []string{"arn"}, DiffKeyColumns([]string{"arn"}, []string{"arn", "name"}))
+	require.Equal(t, []string{"id"}, DiffKeyColumns([]string{"selefra_id"}, []string{"selefra_id", "id", "name"}))
+	require.Equal(t, []string{"name", "region"}, DiffKeyColumns(nil, []string{"region", "selefra_id", "name", "aws_s3_buckets_selefra_id"}))
+	require.Equal(t, []string{"id", "account_id", "region"}, DiffKeyColumns(nil, []string{"region", "id", "account_id", "selefra_id"}))
+	require.Equal(t, []string{"arn"}, DiffKeyColumns(nil, []string{"region", "arn", "id", "account_id"}))
+}
+
+func TestDiffRowsDuplicates(t *testing.T) {
+	from := []map[string]interface{}{
+		{"id": "vpc-1", "region": "us-east-1", "cidr": "10.0.0.0/16"},
+		{"id": "vpc-1", "region": "us-west-1", "cidr": "10.1.0.0/16"},
+	}
+	to := []map[string]interface{}{
+		{"id": "vpc-1", "region": "us-east-1", "cidr": "10.0.0.0/16"},
+		{"id": "vpc-1", "region": "us-west-1", "cidr": "10.2.0.0/16"},
+	}
+	d := DiffRows("aws_ec2_vpcs", DiffKeyColumns(nil, []string{"id", "region", "cidr"}), from, to)
+	require.Equal(t, 0, d.Duplicates)
+	require.Equal(t, 1, d.Modified)
+
+	d = DiffRows("aws_ec2_vpcs", []string{"id"}, from, to)
+	require.Equal(t, 2, d.Duplicates)
+}
+
+func TestDiffRows(t *testing.T) {
+	from := []map[string]interface{}{
+		{"arn": "arn:1", "public": false, "selefra_id": "a"},
+		{"arn": "arn:2", "public": false, "selefra_id": "b"},
+		{"arn": "arn:3", "public": true, "selefra_id": "c"},
+	}
+	to := []map[string]interface{}{
+		{"arn": "arn:1", "public": false, "selefra_id": "d"},
+		{"arn": "arn:2", "public": true, "selefra_id": "e"},
+		{"arn": "arn:4", "public": false, "selefra_id": "f"},
+	}
+	d := DiffRows("aws_s3_buckets", []string{"arn"}, from, to)
+	require.Equal(t, "aws_s3_buckets", d.Table)
+	require.Equal(t, 1, d.Added)
+	require.Equal(t, 1, d.Removed)
+	require.Equal(t, 1, d.Modified)
+	require.Equal(t, []*ResourceChange{
+		{Change: ChangeModified, Key: map[string]interface{}{"arn": "arn:2"}, Columns: []ColumnChange{{Column: "public", From: false, To: true}}},
+		{Change: ChangeAdded, Key: map[string]interface{}{"arn": "arn:4"}},
+		{Change: ChangeRemoved, Key: map[string]interface{}{"arn": "arn:3"}},
+	}, d.Changes)
+
+	require.True(t, DiffRows("aws_s3_buckets", []string{"arn"}, from, from).Empty())
+}
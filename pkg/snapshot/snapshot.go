@@ -0,0 +1,215 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/selefra/selefra-provider-sdk/storage"
+	"github.com/selefra/selefra/pkg/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FetchTimeKey is the key in selefra_meta_kv of a provider schema which keeps the time its data was fetched
+const FetchTimeKey = "fetch_time"
+
+// TableFetchTimeKeyPrefix prefix the keys in selefra_meta_kv of a provider schema which keep the time each root table was fetched
+const TableFetchTimeKeyPrefix = "fetch_time:"
+
+// idLayout is the layout of snapshot id, it is the utc time the data of snapshot was fetched
+const idLayout = "20060102150405"
+
+const schemaInfix = "_snapshot_"
+
+// maxIdentifierLength is the max bytes of a postgres identifier, postgres truncates the longer ones
+const maxIdentifierLength = 63
+
+// Current is the snapshot target of the data fetched last
+const Current = "current"
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ErrSnapshotNotFound is returned when no snapshot matches the target
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot is a retained copy of a provider schema, it is the schema replaced by a later fetch
+type Snapshot struct {
+	Id     string
+	Schema string
+	Time   time.Time
+}
+
+// Id return the snapshot id of data fetched at t
+func Id(t time.Time) string {
+	return t.UTC().Format(idLayout)
+}
+
+// DerivedSchema return the name of the schema derived from schema with suffix, such as its snapshots and its staging
+// schema. schema is shortened and suffixed with its hash when the name exceeds the identifier limit of postgres,
+// so that the name is not truncated by postgres and the derived schemas of long schemas do not collide
+func DerivedSchema(schema, suffix string) string {
+	if len(schema)+len(suffix) <= maxIdentifierLength {
+		return schema + suffix
+	}
+	sum := sha256.Sum256([]byte(schema))
+	hash := hex.EncodeToString(sum[:4])
+	keep := maxIdentifierLength - len(suffix) - len(hash) - 1
+	if keep < 0 {
+		keep = 0
+	}
+	return schema[:keep] + "_" + hash + suffix
+}
+
+// SchemaName return the name of the snapshot schema of id for schema
+func SchemaName(schema, id string) string {
+	return DerivedSchema(schema, schemaInfix+id)
+}
+
+// parseSchemaName return the snapshot of name if name is a snapshot schema of schema
+func parseSchemaName(schema, name string) (*Snapshot, bool) {
+	// ids have the same length as their layout, so are the prefixes of the snapshot schemas of schema
+	layoutName := SchemaName(schema, idLayout)
+	prefix := layoutName[:len(layoutName)-len(idLayout)]
+	if !strings.HasPrefix(name, prefix) {
+		return nil, false
+	}
+	id := strings.TrimPrefix(name, prefix)
+	t, err := time.ParseInLocation(idLayout, id, time.UTC)
+	if err != nil {
+		return nil, false
+	}
+	return &Snapshot{Id: id, Schema: name, Time: t}, true
+}
+
+// ParseTime parse the time of a snapshot target, a date means the end of the day
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			if layout == "2006-01-02" {
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid snapshot %s, must be current, a snapshot id, a date like 2006-01-02 or a RFC3339 time", s)
+}
+
+// List return the snapshots of schema, the newest first
+func List(ctx context.Context, sto storage.Storage, schema string) ([]*Snapshot, error) {
+	names, err := schemaNames(ctx, sto)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, name := range names {
+		if s, ok := parseSchemaName(schema, name); ok {
+			snapshots = append(snapshots, s)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// Prune drop the snapshots of schema except the newest keep ones, return the dropped snapshots
+func Prune(ctx context.Context, sto storage.Storage, schema string, keep int) ([]*Snapshot, error) {
+	snapshots, err := List(ctx, sto, schema)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	var pruned []*Snapshot
+	for i := keep; i < len(snapshots); i++ {
+		if diag := sto.Exec(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", snapshots[i].Schema)); diag != nil && diag.HasError() {
+			return pruned, errors.New(diag.ToString())
+		}
+		pruned = append(pruned, snapshots[i])
+	}
+	return pruned, nil
+}
+
+// FetchTime return the time the data of schema was fetched, ok is false if schema does not exist or the time is unknown
+func FetchTime(ctx context.Context, sto storage.Storage, schema string) (t time.Time, ok bool, err error) {
+	names, err := schemaNames(ctx, sto)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var exists bool
+	for _, name := range names {
+		if name == schema {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		return time.Time{}, false, nil
+	}
+	res, diag := sto.Query(ctx, fmt.Sprintf(`SELECT value FROM %s.selefra_meta_kv WHERE "key" = $1`, schema), FetchTimeKey)
+	if diag != nil && diag.HasError() {
+		// the schema has no key value table, its data was not fetched
+		return time.Time{}, false, nil
+	}
+	rows, diag := res.ReadRows(-1)
+	if diag != nil && diag.HasError() {
+		return time.Time{}, false, errors.New(diag.ToString())
+	}
+	for _, row := range rows.GetMatrix() {
+		t, err := time.Parse(time.RFC3339, utils.Strava(row[0]))
+		if err != nil {
+			return time.Time{}, false, nil
+		}
+		return t, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// Resolve return the schema to read for target, target is current, a snapshot id or a time, for a time
+// the newest data fetched at or before it is used, which may be schema itself
+func Resolve(ctx context.Context, sto storage.Storage, schema, target string) (string, error) {
+	if target == Current {
+		return schema, nil
+	}
+	snapshots, err := List(ctx, sto, schema)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range snapshots {
+		if s.Id == target {
+			return s.Schema, nil
+		}
+	}
+	t, err := ParseTime(target)
+	if err != nil {
+		return "", err
+	}
+	if fetchTime, ok, err := FetchTime(ctx, sto, schema); err != nil {
+		return "", err
+	} else if ok && !fetchTime.After(t) {
+		return schema, nil
+	}
+	for _, s := range snapshots {
+		if !s.Time.After(t) {
+			return s.Schema, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no data of %s was fetched before %s", ErrSnapshotNotFound, schema, t.Format(time.RFC3339))
+}
+
+func schemaNames(ctx context.Context, sto storage.Storage) ([]string, error) {
+	rows, err := queryMatrix(ctx, sto, "SELECT nspname FROM pg_namespace")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, row := range rows {
+		names = append(names, utils.Strava(row[0]))
+	}
+	return names, nil
+}
@@ -0,0 +1,60 @@
+package snapshot
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaName(t *testing.T) {
+	fetchTime := time.Date(2022, 11, 15, 8, 30, 0, 0, time.UTC)
+	id := Id(fetchTime)
+	require.Equal(t, "20221115083000", id)
+
+	name := SchemaName("aws_latest_aws_01", id)
+	require.Equal(t, "aws_latest_aws_01_snapshot_20221115083000", name)
+
+	s, ok := parseSchemaName("aws_latest_aws_01", name)
+	require.True(t, ok)
+	require.Equal(t, id, s.Id)
+	require.True(t, fetchTime.Equal(s.Time))
+
+	_, ok = parseSchemaName("aws_latest_aws_02", name)
+	require.False(t, ok)
+	_, ok = parseSchemaName("aws_latest_aws_01", "aws_latest_aws_01_staging")
+	require.False(t, ok)
+
+	// long schemas are hashed rather than truncated by postgres
+	long := "azure_v0110_azure_production_subscription_eastus_0123456789"
+	name = SchemaName(long, id)
+	require.Len(t, name, maxIdentifierLength)
+	require.True(t, strings.HasSuffix(name, "_snapshot_20221115083000"))
+	s, ok = parseSchemaName(long, name)
+	require.True(t, ok)
+	require.Equal(t, id, s.Id)
+	_, ok = parseSchemaName(long+"x", name)
+	require.False(t, ok)
+	require.NotEqual(t, SchemaName(long+"x", id), name)
+}
+
+func TestDerivedSchema(t *testing.T) {
+	require.Equal(t, "aws_latest_aws_01_staging", DerivedSchema("aws_latest_aws_01", "_staging"))
+	long := strings.Repeat("a", 60)
+	require.Len(t, DerivedSchema(long, "_staging"), maxIdentifierLength)
+	require.NotEqual(t, DerivedSchema(long, "_staging"), DerivedSchema(long+"b", "_staging"))
+	require.Equal(t, DerivedSchema(long, "_staging"), DerivedSchema(long, "_staging"))
+}
+
+func TestParseTime(t *testing.T) {
+	got, err := ParseTime("2022-11-15")
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2022, 11, 15, 23, 59, 59, 0, time.Local), got)
+
+	got, err = ParseTime("2022-11-15T08:30:00Z")
+	require.Nil(t, err)
+	require.True(t, time.Date(2022, 11, 15, 8, 30, 0, 0, time.UTC).Equal(got))
+
+	_, err = ParseTime("last tuesday")
+	require.NotNil(t, err)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/mitchellh/go-homedir"
 	"github.com/selefra/selefra/global"
+	"github.com/selefra/selefra/pkg/lockfile"
 	"github.com/selefra/selefra/pkg/modules"
 	"gopkg.in/yaml.v3"
 	"os"
@@ -156,9 +158,11 @@ func GetCredentialsToken() (string, error) {
 	return token, nil
 }
 
-func CreateSource(path, version, latest string) (string, string) {
-	if latest == "latest" {
-		return "selefra/" + path + "@" + version, "selefra/" + path + "@latest"
+// CreateSource return the source of provider path at version, and the source of the version declared in config
+// if it is latest or a constraint resolved to version, otherwise empty
+func CreateSource(path, version, configVersion string) (string, string) {
+	if configVersion != "" && configVersion != version {
+		return "selefra/" + path + "@" + version, "selefra/" + path + "@" + configVersion
 	}
 	return "selefra/" + path + "@" + version, ""
 }
@@ -223,8 +227,9 @@ func getModulesModulesSupplement(ctx context.Context, modulesName, version strin
 	return supplement, err
 }
 
-var LatestVersion string
-
+// ModulesUpdate download the module modulesName into modulesPath, a module of org is downloaded from selefra cloud.
+// the module is locked in the lock file of the workspace, a locked module is downloaded with the locked version
+// and must match the locked hash
 func ModulesUpdate(modulesName string, modulesPath string, org string) error {
 	_, config, err := Home()
 	if err != nil {
@@ -239,6 +244,10 @@ func ModulesUpdate(modulesName string, modulesPath string, org string) error {
 	if err != nil {
 		return err
 	}
+	lock, err := lockfile.Shared(global.WorkSpace())
+	if err != nil {
+		return err
+	}
 
 	if org != "" {
 		url := "https://" + global.SERVER + "/cli/download/" + org + "/" + global.Token() + "/" + modulesName + ".zip"
@@ -253,46 +262,63 @@ func ModulesUpdate(modulesName string, modulesPath string, org string) error {
 		if err != nil {
 			return err
 		}
-		return nil
-	} else {
-		if LatestVersion == "" {
-			metadata, err := getModulesMetadata(context.Background(), modulesName)
-			if err != nil {
-				return err
-			}
-			LatestVersion = metadata.LatestVersion
+		return lockModule(lock, "app.selefra.io/"+org+"/"+modulesName, "", filepath.Join(modulesPath, modulesName))
+	}
+
+	name := "selefra/" + modulesName
+	var version string
+	if locked := lock.Module(name); locked != nil {
+		version = locked.Version
+	}
+	if version == "" {
+		metadata, err := getModulesMetadata(context.Background(), modulesName)
+		if err != nil {
+			return err
 		}
+		version = metadata.LatestVersion
+	}
+	_, e := os.Stat(filepath.Join(modulesPath, modulesName))
+	if configMap["modules"+"/"+modulesName] != version || e != nil {
+		supplement, err := getModulesModulesSupplement(context.Background(), modulesName, version)
 		if err != nil {
 			return err
 		}
-		_, e := os.Stat(filepath.Join(modulesPath, modulesName))
-		if configMap["modules"+"/"+modulesName] == LatestVersion && e == nil {
-			return nil
-		} else {
-			supplement, err := getModulesModulesSupplement(context.Background(), modulesName, LatestVersion)
-			if err != nil {
-				return err
-			}
-			url := supplement.Source + "/releases/download/" + LatestVersion + "/" + modulesName + ".zip"
-			err = os.RemoveAll(filepath.Join(modulesPath, modulesName))
-			if err != nil {
-				return err
-			}
-			err = modules.DownloadModule(url, modulesPath)
-			if err != nil {
-				return err
-			}
-			configMap["modules"+"/"+modulesName] = LatestVersion
-			c, err := json.Marshal(configMap)
-			if err != nil {
-				return err
-			}
-			err = os.Remove(config)
-			if err != nil {
-				return err
-			}
-			err = os.WriteFile(config, c, 0644)
+		url := supplement.Source + "/releases/download/" + version + "/" + modulesName + ".zip"
+		err = os.RemoveAll(filepath.Join(modulesPath, modulesName))
+		if err != nil {
+			return err
+		}
+		err = modules.DownloadModule(url, modulesPath)
+		if err != nil {
+			return err
+		}
+		configMap["modules"+"/"+modulesName] = version
+		c, err := json.Marshal(configMap)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(config)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(config, c, 0644)
+		if err != nil {
+			return err
 		}
 	}
-	return nil
+	return lockModule(lock, name, version, filepath.Join(modulesPath, modulesName))
+}
+
+// lockModule lock the module name downloaded into dir at version, the module locked at the same version
+// must not be changed
+func lockModule(lock *lockfile.LockFile, name, version, dir string) error {
+	hash, err := lockfile.HashDir(dir)
+	if err != nil {
+		return err
+	}
+	if locked := lock.Module(name); locked != nil && locked.Version == version && locked.Hash != hash {
+		return fmt.Errorf("module %s does not match its hash in %s, run selefra lock upgrade if the change is expected", name, lockfile.FileName)
+	}
+	lock.SetModule(&lockfile.Module{Name: name, Version: version, Hash: hash})
+	return lock.Save()
 }
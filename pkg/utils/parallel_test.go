@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallel(t *testing.T) {
+	var running, maxRunning int32
+	var mux sync.Mutex
+	var called []int
+	Parallel(10, 3, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mux.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		called = append(called, i)
+		mux.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	})
+	require.Len(t, called, 10)
+	require.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, called)
+	require.LessOrEqual(t, maxRunning, int32(3))
+	require.Greater(t, maxRunning, int32(1))
+}
+
+func TestParallelEdgeCases(t *testing.T) {
+	var calls int32
+	Parallel(0, 4, func(i int) {
+		atomic.AddInt32(&calls, 1)
+	})
+	require.Equal(t, int32(0), calls)
+
+	// parallelism below 1 runs the calls one by one
+	for _, parallelism := range []int{0, -1} {
+		var running, maxRunning, total int32
+		Parallel(5, parallelism, func(i int) {
+			n := atomic.AddInt32(&running, 1)
+			if n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&total, 1)
+		})
+		require.Equal(t, int32(5), total)
+		require.Equal(t, int32(1), maxRunning)
+	}
+}
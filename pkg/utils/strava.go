@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/google/uuid"
 	"strconv"
+	"strings"
 )
 
 func Strava(value interface{}) string {
@@ -64,3 +65,12 @@ func Strava(value interface{}) string {
 
 	return key
 }
+
+// Truncate return s in one line and at most n characters, a longer s is cut and ends with "..."
+func Truncate(s string, n int) string {
+	r := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-3]) + "..."
+}
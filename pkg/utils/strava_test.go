@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "short", Truncate("short", 10))
+	require.Equal(t, "one line", Truncate("one\nline", 10))
+	require.Equal(t, "0123456...", Truncate("0123456789abc", 10))
+	require.Equal(t, "0123456789", Truncate("0123456789", 10))
+	// multi-byte characters are not split
+	require.Equal(t, "存储桶没有加密...", Truncate("存储桶没有加密的存储卷", 10))
+	require.Equal(t, "存储桶没有加密的存储", Truncate("存储桶没有加密的存储", 10))
+}
@@ -70,6 +70,33 @@ func (p *Progress) IOBar(name string, reader io.Reader, total int64) {
 	p.p.Wait()
 }
 
+// ReaderBar add a bar of name which shows the bytes read from the returned reader of reader like IOBar,
+// total is -1 when the size is unknown, the bar is finished by Complete or Abort
+func (p *Progress) ReaderBar(name string, reader io.Reader, total int64) io.ReadCloser {
+	bar := p.p.New(total,
+		mpb.BarStyle().Rbound("|"),
+		mpb.PrependDecorators(
+			decor.CountersKibiByte("% .2f / % .2f"),
+		),
+		mpb.AppendDecorators(
+			decor.EwmaETA(decor.ET_STYLE_GO, 90),
+			decor.Name(" ] "),
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+		),
+	)
+	p.bars.Store(name, &Bar{b: bar, Name: name, Start: time.Now(), Current: time.Now()})
+	return bar.ProxyReader(reader)
+}
+
+// Complete finish the bar of name at its current value, the bar whose total is unknown never completes by itself
+func (p *Progress) Complete(name string) {
+	bar, ok := p.bars.Load(name)
+	if !ok {
+		return
+	}
+	bar.(*Bar).b.SetTotal(-1, true)
+}
+
 // Add adds a new bar to the progress
 func (p *Progress) Add(name string, total int64) {
 
@@ -139,6 +166,15 @@ func (p *Progress) Done(name string) {
 	bar.(*Bar).b.EnableTriggerComplete()
 }
 
+// Abort stop the bar which will never complete, the bar is kept on screen
+func (p *Progress) Abort(name string) {
+	bar, ok := p.bars.Load(name)
+	if !ok {
+		return
+	}
+	bar.(*Bar).b.Abort(false)
+}
+
 func (p *Progress) Wait(name string) {
 	bar, ok := p.bars.Load(name)
 	if !ok {